@@ -0,0 +1,138 @@
+// match-sim 确定性的匹配模拟器，用于调整算法权重、阈值和搜索窗口
+//
+// 按种子生成合成玩家（MMR分布、到达速率、区域和延迟分布），在模拟时钟上
+// 使用内存队列运行任意已注册的匹配算法，输出等待时间百分位数、匹配质量分布和对局MMR跨度。
+// 不依赖 Redis 和 Postgres，相同的种子、参数和配置总是得到相同的结果。
+//
+// 原先按排队时间放大匹配得分的 QueueTimeMultiplier 已由各模式的 search_window 阶梯取代：
+// 排队时间不再提高得分，而是放宽可接受的MMR差、等级差和延迟上限。-queue-time-multiplier
+// 调整窗口放宽的速度，各阶梯的 after 除以该倍数（2 表示窗口提前一倍时间放宽），
+// 得到满意的结果后按相同比例修改 config.yml 中该模式 search_window 的 after。
+//
+// 在项目根目录运行，读取 configs/config.yml：
+//
+//	go run ./cmd/match-sim -algorithm glicko -mode ranked -seed 42 -duration 2h -rate 3
+//	go run ./cmd/match-sim -mode classic -matcher batch -batch-iterations 5000
+//	go run ./cmd/match-sim -mode ranked -queue-time-multiplier 1.5
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"math"
+	"os"
+	"time"
+
+	"github.com/mangooer/gamehub-arena/internal/config"
+	"github.com/mangooer/gamehub-arena/pkg/algorithm"
+)
+
+// 模拟时钟起点，固定以保证结果可复现
+var simulationStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func main() {
+	var (
+		algorithmName = flag.String("algorithm", "", "matching algorithm, defaults to match.default_algorithm")
+		gameMode      = flag.String("mode", "", "game mode, defaults to the first configured queue mode")
+		matcherName   = flag.String("matcher", "", "greedy or batch, defaults to the mode's configured matcher")
+		iterations    = flag.Int("batch-iterations", 2000, "optimization iterations per tick for the batch matcher")
+		queueTimeMult = flag.Float64("queue-time-multiplier", 1, "how fast search windows widen with queue time; divides the after of each search_window step")
+		seed          = flag.Int64("seed", 1, "random seed for the synthetic population")
+		duration      = flag.Duration("duration", time.Hour, "simulated duration")
+		tick          = flag.Duration("tick", time.Second, "simulated matching interval")
+		rate          = flag.Float64("rate", 2, "mean player arrivals per simulated second")
+		mmrMean       = flag.Float64("mmr-mean", 1500, "mean of the MMR distribution")
+		mmrStdDev     = flag.Float64("mmr-stddev", 300, "standard deviation of the MMR distribution")
+		pingMean      = flag.Float64("ping-mean", 30, "mean ping to home region data centers (ms)")
+		pingSpread    = flag.Float64("ping-spread", 20, "spread of ping to home region data centers (ms)")
+		crossPing     = flag.Int("cross-region-ping", 40, "extra ping to neighbor region data centers (ms)")
+		jsonOutput    = flag.Bool("json", false, "print the report as JSON")
+	)
+	flag.Parse()
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	matchConfig := &cfg.Match
+
+	if *algorithmName == "" {
+		*algorithmName = matchConfig.DefaultAlgorithm
+	}
+	if *gameMode == "" && len(matchConfig.Queue.GameModes) > 0 {
+		*gameMode = matchConfig.Queue.GameModes[0]
+	}
+	if *tick <= 0 || *duration < *tick {
+		log.Fatalf("Invalid tick %s for duration %s", *tick, *duration)
+	}
+
+	if *matcherName != "" {
+		if *matcherName != config.MatcherGreedy && *matcherName != config.MatcherBatch {
+			log.Fatalf("Invalid matcher %q", *matcherName)
+		}
+		mode := matchConfig.Modes[*gameMode]
+		mode.Matcher = *matcherName
+		if matchConfig.Modes == nil {
+			matchConfig.Modes = make(map[string]config.ModeConfig)
+		}
+		matchConfig.Modes[*gameMode] = mode
+	}
+	if *queueTimeMult <= 0 {
+		log.Fatalf("Invalid queue time multiplier %v", *queueTimeMult)
+	}
+	scaleSearchWindow(matchConfig, *gameMode, *queueTimeMult)
+	// 模拟中批量匹配只按迭代上限停止，不使用时间预算
+	matchConfig.Batch.MaxIterations = *iterations
+
+	// 模拟不受线上启用开关限制，便于评估尚未启用的算法
+	if algorithmConfig, ok := matchConfig.Algorithms[*algorithmName]; ok {
+		algorithmConfig.Enabled = true
+		matchConfig.Algorithms[*algorithmName] = algorithmConfig
+	}
+
+	factory := algorithm.InitFactory()
+	factory.Configure(matchConfig)
+	matcher, err := factory.GetAlgorithm(*algorithmName)
+	if err != nil {
+		log.Fatalf("Failed to get algorithm %q (available: %v): %v", *algorithmName, factory.AvailableAlgorithms(), err)
+	}
+
+	players := newPopulation(*seed, simulationStart, populationConfig{
+		ArrivalRate:     *rate,
+		MMRMean:         *mmrMean,
+		MMRStdDev:       *mmrStdDev,
+		PingMean:        *pingMean,
+		PingSpread:      *pingSpread,
+		CrossRegionPing: *crossPing,
+	}, matchConfig, *gameMode)
+
+	sim := newSimulator(matcher, matchConfig, *gameMode, *tick, *seed)
+	summary := sim.run(context.Background(), players, simulationStart, *duration).summary(matcher, *gameMode, *seed, *duration)
+	summary.Matcher = matchConfig.Matcher(*gameMode)
+	summary.QueueTimeMultiplier = *queueTimeMult
+
+	if *jsonOutput {
+		err = summary.writeJSON(os.Stdout)
+	} else {
+		err = summary.writeText(os.Stdout)
+	}
+	if err != nil {
+		log.Fatalf("Failed to write report: %v", err)
+	}
+}
+
+// 按倍数加快或放慢模式搜索窗口的放宽，各阶梯的生效时间除以 multiplier
+func scaleSearchWindow(cfg *config.MatchConfig, gameMode string, multiplier float64) {
+	mode, ok := cfg.Modes[gameMode]
+	if !ok || multiplier == 1 {
+		return
+	}
+	steps := make([]config.SearchWindowStep, len(mode.SearchWindow))
+	for i, step := range mode.SearchWindow {
+		step.After = int(math.Round(float64(step.After) / multiplier))
+		steps[i] = step
+	}
+	mode.SearchWindow = steps
+	cfg.Modes[gameMode] = mode
+}
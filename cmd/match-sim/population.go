@@ -0,0 +1,142 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/mangooer/gamehub-arena/internal/config"
+	"github.com/mangooer/gamehub-arena/pkg/algorithm"
+)
+
+// 合成玩家群体的分布参数
+type populationConfig struct {
+	ArrivalRate     float64 // 平均每秒到达的玩家数（泊松到达）
+	MMRMean         float64 // MMR 正态分布均值
+	MMRStdDev       float64 // MMR 正态分布标准差
+	PingMean        float64 // 到所在区域机房的平均延迟（毫秒）
+	PingSpread      float64 // 延迟的半正态分布尺度
+	CrossRegionPing int     // 到相邻区域机房的额外延迟
+}
+
+// 由种子决定的玩家生成器，同一种子和参数总是生成同样的玩家序列
+type population struct {
+	rng      *rand.Rand
+	cfg      populationConfig
+	match    *config.MatchConfig
+	gameMode string
+	regions  []string
+
+	nextID      uint64
+	nextArrival time.Time
+}
+
+func newPopulation(seed int64, start time.Time, cfg populationConfig, match *config.MatchConfig, gameMode string) *population {
+	p := &population{
+		rng:      rand.New(rand.NewSource(seed)),
+		cfg:      cfg,
+		match:    match,
+		gameMode: gameMode,
+		regions:  match.RegionNames(),
+	}
+	p.nextArrival = start.Add(p.interArrival())
+	return p
+}
+
+// arrivals 返回 now 之前（含）到达、尚未返回过的玩家
+func (p *population) arrivals(now time.Time) []*algorithm.Player {
+	var players []*algorithm.Player
+	for !p.nextArrival.After(now) {
+		players = append(players, p.newPlayer(p.nextArrival))
+		p.nextArrival = p.nextArrival.Add(p.interArrival())
+	}
+	return players
+}
+
+func (p *population) interArrival() time.Duration {
+	if p.cfg.ArrivalRate <= 0 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(p.rng.ExpFloat64() / p.cfg.ArrivalRate * float64(time.Second))
+}
+
+func (p *population) newPlayer(at time.Time) *algorithm.Player {
+	p.nextID++
+	mmr := math.Max(0, p.rng.NormFloat64()*p.cfg.MMRStdDev+p.cfg.MMRMean)
+
+	// 等级和胜率与MMR弱相关
+	z := 0.0
+	if p.cfg.MMRStdDev > 0 {
+		z = (mmr - p.cfg.MMRMean) / p.cfg.MMRStdDev
+	}
+	level := clampInt(int(mmr/40)+p.rng.Intn(11)-5, 1, 100)
+	winRate := math.Max(0, math.Min(1, 0.5+0.05*z+0.05*p.rng.NormFloat64()))
+
+	// 场次服从指数分布，包含一部分定级期的新玩家；场次越少评级越不确定
+	games := int(p.rng.ExpFloat64() * 150)
+	wins := int(float64(games) * winRate)
+	uncertainty := math.Exp(-float64(games) / 50)
+
+	player := &algorithm.Player{
+		ID:              p.nextID,
+		Level:           level,
+		WinRate:         winRate,
+		WinCount:        wins,
+		LoseCount:       games - wins,
+		QueueTime:       at,
+		GameMode:        p.gameMode,
+		Region:          p.regions[p.rng.Intn(len(p.regions))],
+		MMR:             mmr,
+		Confidence:      1 - uncertainty,
+		RatingDeviation: 50 + 300*uncertainty,
+		Sigma:           1 + (25.0/3-1)*uncertainty,
+	}
+	p.assignPings(player)
+	p.assignRoles(player)
+	return player
+}
+
+// 所在区域的机房延迟围绕同一基准波动，相邻区域的机房额外增加跨区延迟
+func (p *population) assignPings(player *algorithm.Player) {
+	base := p.cfg.PingMean + math.Abs(p.rng.NormFloat64())*p.cfg.PingSpread
+	player.Ping = int(base)
+
+	region := p.match.Regions[player.Region]
+	if len(region.DataCenters) == 0 {
+		return
+	}
+	player.Pings = make(map[string]int)
+	for _, dc := range region.DataCenters {
+		player.Pings[dc] = int(base) + p.rng.Intn(10)
+	}
+	for _, neighbor := range region.Neighbors {
+		for _, dc := range p.match.Regions[neighbor].DataCenters {
+			if _, ok := player.Pings[dc]; !ok {
+				player.Pings[dc] = int(base) + p.cfg.CrossRegionPing + p.rng.Intn(20)
+			}
+		}
+	}
+	for _, ping := range player.Pings {
+		player.Ping = min(player.Ping, ping)
+	}
+}
+
+// 分角色模式下随机生成一到两个偏好角色，部分玩家接受补位或不填写偏好
+func (p *population) assignRoles(player *algorithm.Player) {
+	roles := p.match.Roles(p.gameMode)
+	if len(roles) == 0 || p.rng.Float64() < 0.1 {
+		return
+	}
+	order := p.rng.Perm(len(roles))
+	player.Roles = []string{roles[order[0]]}
+	if len(roles) > 1 && p.rng.Float64() < 0.6 {
+		player.Roles = append(player.Roles, roles[order[1]])
+	}
+	if p.rng.Float64() < 0.3 {
+		player.Roles = append(player.Roles, algorithm.RoleFill)
+	}
+}
+
+func clampInt(v, lo, hi int) int {
+	return max(lo, min(hi, v))
+}
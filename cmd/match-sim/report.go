@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mangooer/gamehub-arena/pkg/algorithm"
+)
+
+// 匹配质量直方图的分桶数，每桶宽 1/qualityBuckets
+const qualityBuckets = 10
+
+// 模拟过程中收集的原始样本
+type report struct {
+	arrived   int
+	timedOut  int
+	waiting   int
+	rejected  int // 算法给出对局但没有满足延迟上限的机房的次数
+	peakQueue int
+
+	matches     int
+	crossRegion int
+	waits       []float64 // 每名成局玩家的等待秒数
+	qualities   []float64
+	spreads     []float64 // 每场对局内最高与最低MMR之差
+	teamDiffs   []float64 // 每场对局两队平均MMR之差
+	pings       []float64 // 每场对局的最大延迟
+	fairness    []float64 // 每场对局的预测公平度
+}
+
+func newReport() *report {
+	return &report{}
+}
+
+func (r *report) recordMatch(result *algorithm.MatchResult, now time.Time, maxPing int) {
+	r.matches++
+	r.qualities = append(r.qualities, result.Quality)
+	r.pings = append(r.pings, float64(maxPing))
+
+	lowest, highest := math.Inf(1), math.Inf(-1)
+	crossRegion := false
+	for _, p := range result.Players {
+		r.waits = append(r.waits, now.Sub(p.QueueTime).Seconds())
+		lowest = math.Min(lowest, p.EffectiveMMR())
+		highest = math.Max(highest, p.EffectiveMMR())
+		crossRegion = crossRegion || p.Region != result.Players[0].Region
+	}
+	r.spreads = append(r.spreads, highest-lowest)
+	if crossRegion {
+		r.crossRegion++
+	}
+
+	if result.Prediction != nil {
+		r.fairness = append(r.fairness, result.Prediction.Fairness)
+	}
+	if len(result.Teams) == 2 {
+		r.teamDiffs = append(r.teamDiffs, math.Abs(result.Teams[0].AverageMMR-result.Teams[1].AverageMMR))
+	}
+}
+
+// 一组样本的分布摘要
+type distribution struct {
+	Mean float64 `json:"mean"`
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	P99  float64 `json:"p99"`
+	Max  float64 `json:"max"`
+}
+
+func summarize(samples []float64) distribution {
+	if len(samples) == 0 {
+		return distribution{}
+	}
+	sorted := append([]float64(nil), samples...)
+	sort.Float64s(sorted)
+	var sum float64
+	for _, v := range sorted {
+		sum += v
+	}
+	return distribution{
+		Mean: sum / float64(len(sorted)),
+		P50:  percentile(sorted, 50),
+		P90:  percentile(sorted, 90),
+		P99:  percentile(sorted, 99),
+		Max:  sorted[len(sorted)-1],
+	}
+}
+
+// 最近秩法百分位数，sorted 必须已升序排列
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(0, rank-1)]
+}
+
+// 模拟结果，可输出为文本或JSON
+type summary struct {
+	Algorithm string  `json:"algorithm"`
+	Version   string  `json:"version"`
+	Matcher   string  `json:"matcher"`
+	GameMode  string  `json:"game_mode"`
+	Seed      int64   `json:"seed"`
+	Duration  string  `json:"duration"`
+	Arrived   int     `json:"arrived"`
+	Matched   int     `json:"matched_players"`
+	TimedOut  int     `json:"timed_out"`
+	Waiting   int     `json:"still_waiting"`
+	PeakQueue int     `json:"peak_queue"`
+	Matches   int     `json:"matches"`
+	Rejected  int     `json:"rejected_by_ping"`
+	Cross     float64 `json:"cross_region_ratio"`
+
+	QueueTimeMultiplier float64 `json:"queue_time_multiplier"` // 搜索窗口放宽速度的倍数，取代原先的 QueueTimeMultiplier
+
+	WaitSeconds    distribution `json:"wait_seconds"`
+	Quality        distribution `json:"quality"`
+	QualityBuckets []int        `json:"quality_histogram"`
+	MMRSpread      distribution `json:"mmr_spread"`
+	TeamMMRDiff    distribution `json:"team_mmr_diff"`
+	MaxPing        distribution `json:"max_ping"`
+	Fairness       distribution `json:"fairness"`
+}
+
+func (r *report) summary(matcher algorithm.MatchingAlgorithm, gameMode string, seed int64, duration time.Duration) *summary {
+	s := &summary{
+		Algorithm:      matcher.Name(),
+		Version:        matcher.Version(),
+		GameMode:       gameMode,
+		Seed:           seed,
+		Duration:       duration.String(),
+		Arrived:        r.arrived,
+		Matched:        len(r.waits),
+		TimedOut:       r.timedOut,
+		Waiting:        r.waiting,
+		PeakQueue:      r.peakQueue,
+		Matches:        r.matches,
+		Rejected:       r.rejected,
+		WaitSeconds:    summarize(r.waits),
+		Quality:        summarize(r.qualities),
+		QualityBuckets: make([]int, qualityBuckets),
+		MMRSpread:      summarize(r.spreads),
+		TeamMMRDiff:    summarize(r.teamDiffs),
+		MaxPing:        summarize(r.pings),
+		Fairness:       summarize(r.fairness),
+	}
+	if r.matches > 0 {
+		s.Cross = float64(r.crossRegion) / float64(r.matches)
+	}
+	for _, q := range r.qualities {
+		s.QualityBuckets[min(qualityBuckets-1, max(0, int(q*qualityBuckets)))]++
+	}
+	return s
+}
+
+func (s *summary) writeJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(s)
+}
+
+func (s *summary) writeText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "algorithm\t%s (%s), %s matcher\n", s.Algorithm, s.Version, s.Matcher)
+	fmt.Fprintf(tw, "game mode\t%s (queue time multiplier %g)\n", s.GameMode, s.QueueTimeMultiplier)
+	fmt.Fprintf(tw, "seed / duration\t%d / %s\n", s.Seed, s.Duration)
+	fmt.Fprintf(tw, "players\tarrived %d, matched %d, timed out %d, still waiting %d, peak queue %d\n",
+		s.Arrived, s.Matched, s.TimedOut, s.Waiting, s.PeakQueue)
+	fmt.Fprintf(tw, "matches\t%d (rejected by ping %d, cross-region %.1f%%)\n", s.Matches, s.Rejected, s.Cross*100)
+	fmt.Fprintln(tw)
+
+	fmt.Fprintln(tw, "metric\tmean\tp50\tp90\tp99\tmax")
+	for _, row := range []struct {
+		name string
+		d    distribution
+	}{
+		{"wait (s)", s.WaitSeconds},
+		{"quality", s.Quality},
+		{"mmr spread", s.MMRSpread},
+		{"team mmr diff", s.TeamMMRDiff},
+		{"max ping (ms)", s.MaxPing},
+		{"fairness", s.Fairness},
+	} {
+		fmt.Fprintf(tw, "%s\t%.2f\t%.2f\t%.2f\t%.2f\t%.2f\n", row.name, row.d.Mean, row.d.P50, row.d.P90, row.d.P99, row.d.Max)
+	}
+	fmt.Fprintln(tw)
+
+	fmt.Fprintln(tw, "quality\tmatches")
+	for i, count := range s.QualityBuckets {
+		fmt.Fprintf(tw, "[%.1f, %.1f)\t%d\n", float64(i)/qualityBuckets, float64(i+1)/qualityBuckets, count)
+	}
+	return tw.Flush()
+}
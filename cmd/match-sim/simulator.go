@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/mangooer/gamehub-arena/internal/config"
+	"github.com/mangooer/gamehub-arena/pkg/algorithm"
+	"github.com/mangooer/gamehub-arena/pkg/match"
+)
+
+// 内存队列上的匹配模拟，使用模拟时钟代替 time.Now
+//
+// 每个 tick 依次：加入到达玩家、移除排队超时的玩家、按MMR顺序为每名未匹配玩家寻找对局，
+// 与引擎的段位协程一致。候选筛选复用 match 包的搜索窗口和双向接受规则。
+// 批量匹配时每个 tick 对整个队列求解，只按迭代上限停止，以保证结果可复现。
+type simulator struct {
+	matcher    algorithm.MatchingAlgorithm
+	config     *config.MatchConfig
+	gameMode   string
+	tick       time.Duration
+	batch      bool
+	iterations int
+	seed       int64
+
+	queue  []*algorithm.Player // 按 MMR、ID 排序
+	report *report
+}
+
+func newSimulator(matcher algorithm.MatchingAlgorithm, cfg *config.MatchConfig, gameMode string, tick time.Duration, seed int64) *simulator {
+	return &simulator{
+		matcher:    matcher,
+		config:     cfg,
+		gameMode:   gameMode,
+		tick:       tick,
+		batch:      cfg.Matcher(gameMode) == config.MatcherBatch,
+		iterations: cfg.Batch.MaxIterations,
+		seed:       seed,
+		report:     newReport(),
+	}
+}
+
+// run 从 start 开始模拟 duration 时长，返回统计结果
+func (s *simulator) run(ctx context.Context, players *population, start time.Time, duration time.Duration) *report {
+	end := start.Add(duration)
+	for now := start.Add(s.tick); !now.After(end); now = now.Add(s.tick) {
+		arrived := players.arrivals(now)
+		s.report.arrived += len(arrived)
+		for _, p := range arrived {
+			s.enqueue(p)
+		}
+		s.expire(now)
+		s.matchOnce(ctx, now)
+		s.report.peakQueue = max(s.report.peakQueue, len(s.queue))
+	}
+	s.report.waiting = len(s.queue)
+	return s.report
+}
+
+func (s *simulator) enqueue(player *algorithm.Player) {
+	i := sort.Search(len(s.queue), func(i int) bool {
+		q := s.queue[i]
+		return q.MMR > player.MMR || (q.MMR == player.MMR && q.ID > player.ID)
+	})
+	s.queue = append(s.queue, nil)
+	copy(s.queue[i+1:], s.queue[i:])
+	s.queue[i] = player
+}
+
+// 移除排队超过队列超时时间的玩家
+func (s *simulator) expire(now time.Time) {
+	timeout := time.Duration(s.config.Queue.Timeout) * time.Second
+	if timeout <= 0 {
+		return
+	}
+	kept := s.queue[:0]
+	for _, p := range s.queue {
+		if now.Sub(p.QueueTime) >= timeout {
+			s.report.timedOut++
+			continue
+		}
+		kept = append(kept, p)
+	}
+	s.queue = kept
+}
+
+func (s *simulator) matchOnce(ctx context.Context, now time.Time) {
+	if len(s.queue) < 2 {
+		return
+	}
+
+	matched := make(map[uint64]bool)
+	if s.batch {
+		results, err := s.matcher.FindBatchMatches(ctx, s.queue, algorithm.BatchOptions{
+			Format:        s.format(now),
+			MaxIterations: s.iterations,
+			Seed:          s.seed + now.Unix(),
+			Compatible: func(a, b *algorithm.Player) bool {
+				return s.compatible(a, b, now)
+			},
+		})
+		if err == nil {
+			for _, result := range results {
+				s.record(ctx, result, now, matched)
+			}
+		}
+	} else {
+		for _, player := range s.queue {
+			if matched[player.ID] {
+				continue
+			}
+			result, err := s.findMatch(ctx, player, s.candidates(player, now, matched), now)
+			if err != nil {
+				// 候选不足或质量未达标，下一个 tick 窗口扩大后重试
+				continue
+			}
+			s.record(ctx, result, now, matched)
+		}
+	}
+
+	kept := s.queue[:0]
+	for _, p := range s.queue {
+		if !matched[p.ID] {
+			kept = append(kept, p)
+		}
+	}
+	s.queue = kept
+}
+
+// 选择机房并记录对局，没有满足延迟上限的机房时对局不成立
+func (s *simulator) record(ctx context.Context, result *algorithm.MatchResult, now time.Time, matched map[uint64]bool) {
+	maxPing, ok := s.assignDataCenter(result, now)
+	if !ok {
+		s.report.rejected++
+		return
+	}
+	for _, p := range result.Players {
+		matched[p.ID] = true
+	}
+	if prediction, err := s.matcher.PredictOutcome(ctx, result.Teams); err == nil {
+		result.Prediction = prediction
+	}
+	s.report.recordMatch(result, now, maxPing)
+}
+
+// 对局格式，满员对局需要有满足延迟上限的机房
+func (s *simulator) format(now time.Time) algorithm.TeamFormat {
+	return algorithm.TeamFormat{
+		Size:  s.config.TeamSize(s.gameMode),
+		Roles: s.config.Roles(s.gameMode),
+		Feasible: func(players []*algorithm.Player) bool {
+			_, _, ok := s.selectDataCenter(players, now)
+			return ok
+		},
+	}
+}
+
+func (s *simulator) findMatch(ctx context.Context, player *algorithm.Player, candidates []*algorithm.Player, now time.Time) (*algorithm.MatchResult, error) {
+	if teamSize := s.config.TeamSize(s.gameMode); teamSize > 1 {
+		return s.matcher.FindTeamMatch(ctx, player, candidates, s.format(now))
+	}
+	result, err := s.matcher.FindOptimalMatch(ctx, player, candidates)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Teams) == 0 {
+		result.Teams, _ = algorithm.BalanceTeams(result.Players, 1)
+	}
+	return result, nil
+}
+
+// 与 QueueManager.GetCandidates 相同的筛选：搜索区域内、MMR在窗口内、双方互相接受，
+// 每个区域最多取 CandidateLimit 名
+func (s *simulator) candidates(player *algorithm.Player, now time.Time, matched map[uint64]bool) []*algorithm.Player {
+	window := match.WindowAt(s.config, player, now)
+	regions := s.searchRegions(player, now)
+	limit := s.config.Queue.CandidateLimit
+
+	taken := make(map[string]int)
+	var candidates []*algorithm.Player
+	for _, p := range s.queue {
+		if p.ID == player.ID || matched[p.ID] {
+			continue
+		}
+		if _, ok := regions[p.Region]; !ok {
+			continue
+		}
+		if math.Abs(p.MMR-player.MMR) > window.MMRDelta {
+			continue
+		}
+		if limit > 0 && taken[p.Region] >= limit {
+			continue
+		}
+		taken[p.Region]++
+		if match.MutuallyAcceptable(player, window, p, match.WindowAt(s.config, p, now)) {
+			candidates = append(candidates, p)
+		}
+	}
+	return candidates
+}
+
+// 与 QueueManager.Compatible 相同：一方在另一方的搜索区域内且双方互相接受
+func (s *simulator) compatible(a, b *algorithm.Player, now time.Time) bool {
+	_, aSearchesB := s.searchRegions(a, now)[b.Region]
+	_, bSearchesA := s.searchRegions(b, now)[a.Region]
+	if !aSearchesB && !bSearchesA {
+		return false
+	}
+	return match.MutuallyAcceptable(a, match.WindowAt(s.config, a, now), b, match.WindowAt(s.config, b, now))
+}
+
+// 所在区域，排队超过跨区等待时间后加入相邻区域
+func (s *simulator) searchRegions(player *algorithm.Player, now time.Time) map[string]struct{} {
+	regions := map[string]struct{}{player.Region: {}}
+	if now.Sub(player.QueueTime) < time.Duration(s.config.Queue.CrossRegionWait)*time.Second {
+		return regions
+	}
+	for _, neighbor := range s.config.Regions[player.Region].Neighbors {
+		if s.config.HasRegion(neighbor) {
+			regions[neighbor] = struct{}{}
+		}
+	}
+	return regions
+}
+
+// 与引擎相同的机房选择，延迟上限为对局中所有玩家窗口的最严格值
+func (s *simulator) assignDataCenter(result *algorithm.MatchResult, now time.Time) (int, bool) {
+	dataCenter, maxPing, ok := s.selectDataCenter(result.Players, now)
+	if !ok {
+		return 0, false
+	}
+	result.DataCenter = dataCenter
+	result.CreatedAt = now
+	return maxPing, true
+}
+
+func (s *simulator) selectDataCenter(players []*algorithm.Player, now time.Time) (string, int, bool) {
+	var regions []string
+	pingLimit := 0
+	for _, p := range players {
+		regions = append(regions, p.Region)
+		pingLimit = match.TighterLimit(pingLimit, match.WindowAt(s.config, p, now).MaxPing)
+	}
+	dataCenter, maxPing, err := algorithm.SelectDataCenter(players, s.config.DataCenters(regions...), pingLimit)
+	if err != nil {
+		return "", 0, false
+	}
+	if dataCenter == "" {
+		// 未配置机房时以玩家自身延迟计
+		for _, p := range players {
+			maxPing = max(maxPing, p.Ping)
+		}
+	}
+	return dataCenter, maxPing, true
+}
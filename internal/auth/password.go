@@ -58,12 +58,16 @@ func (p *PasswordService) HashPassword(password string) (string, error) {
 	if err := p.ValidatePassword(password); err != nil {
 		return "", err
 	}
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), p.cfg.BcryptCost)
+	return p.HashSecret(password)
+}
+
+// 加密不要求密码强度的口令（如房间密码），与用户密码使用相同的 bcrypt 成本
+func (p *PasswordService) HashSecret(secret string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(secret), p.cfg.BcryptCost)
 	if err != nil {
 		return "", err
 	}
-	return string(hashedPassword), nil
-
+	return string(hashed), nil
 }
 
 // 验证密码
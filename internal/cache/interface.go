@@ -20,6 +20,7 @@ type CacheService interface {
 	HGet(ctx context.Context, key, field string) (string, error)
 	HGetAll(ctx context.Context, key string) (map[string]string, error)
 	HDel(ctx context.Context, key string, fields ...string) error
+	HMGet(ctx context.Context, key string, fields ...string) ([]interface{}, error)
 
 	// Set操作
 	SAdd(ctx context.Context, key string, members ...interface{}) error
@@ -35,6 +36,9 @@ type CacheService interface {
 	ZRevRange(ctx context.Context, key string, start, stop int64) ([]string, error)
 	ZScore(ctx context.Context, key string, member string) (float64, error)
 	ZRevRank(ctx context.Context, key string, member string) (int64, error)
+	ZRangeByScore(ctx context.Context, key string, opt *redis.ZRangeBy) ([]string, error)
+	ZCard(ctx context.Context, key string) (int64, error)
+	ZRemRangeByScore(ctx context.Context, key, min, max string) (int64, error)
 
 	// List操作
 	LPush(ctx context.Context, key string, values ...interface{}) error
@@ -43,6 +47,9 @@ type CacheService interface {
 	RPop(ctx context.Context, key string) (string, error)
 	LLen(ctx context.Context, key string) (int64, error)
 
+	// 脚本操作
+	Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error)
+
 	// 分布式锁
 	Lock(ctx context.Context, key string, expiration time.Duration) (bool, error)
 	Unlock(ctx context.Context, key string) error
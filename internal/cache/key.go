@@ -14,9 +14,25 @@ const (
 	KeyRoomPlayers = "room:%s:players" // 房间玩家
 	KeyRoomQueue   = "room:queue"      // 房间队列
 
+	KeyRoomInvite           = "room:invite:%s"               // 房间邀请令牌 -> 房间ID
+	KeyRoomPasswordAttempts = "room:password_attempts:%d:%d" // 用户对某个房间尝试密码的次数
+
 	// 匹配相关键
-	KeyMatchQueue   = "match:queue:%s"   // 匹配队列
-	KeyMatchHistory = "match:history:%d" // 匹配历史
+	KeyMatchQueue        = "match:queue:%s:region:%s"  // 区域匹配队列（按MMR排序）
+	KeyMatchQueuePlayers = "match:queue:%s:players"    // 匹配队列玩家数据
+	KeyMatchQueueTime    = "match:queue:%s:time"       // 匹配队列入队时间
+	KeyMatchQueueMembers = "match:queue:%s:members"    // 玩家ID -> 所在队列单元
+	KeyMatchHistory      = "match:history:%d"          // 匹配历史
+	KeyMatchReady        = "match:ready:%s"            // 就绪确认状态
+	KeyMatchReadyTimers  = "match:ready:deadlines"     // 就绪确认截止时间
+	KeyMatchReadyPlayer  = "match:ready_player:%d"     // 玩家所在的进行中就绪确认 -> 对局ID
+	KeyMatchCooldown     = "match:cooldown:%d"         // 拒绝对局后的排队冷却
+	KeyMatchPriority     = "match:priority:%d"         // 排队超时未成局留下的优先级评分
+	KeyMatchThroughput   = "match:throughput:%s:%s:%d" // 模式、区域、MMR段内近期成局玩家的等待时间
+	KeyMatchBackfill     = "match:backfill:%s"         // 进行中房间的补位空位
+	KeyMatchInstances    = "match:instances"           // 存活的匹配实例（按心跳过期时间排序）
+	KeyMatchLease        = "match:lease:%s"            // 匹配桶租约：实例ID|隔离令牌
+	KeyMatchLeaseToken   = "match:lease:%s:token"      // 匹配桶隔离令牌计数器
 
 	// 排行榜相关键
 	KeyLeaderboard    = "leaderboard:%s"     // 排行榜
@@ -53,10 +69,81 @@ func GameRoomKey(roomCode string) string {
 	return fmt.Sprintf(KeyGameRoom, roomCode)
 }
 
-func MatchQueueKey(gameMode string) string {
-	return fmt.Sprintf(KeyMatchQueue, gameMode)
+func RoomInviteKey(token string) string {
+	return fmt.Sprintf(KeyRoomInvite, token)
+}
+
+func RoomPasswordAttemptsKey(roomID, userID uint64) string {
+	return fmt.Sprintf(KeyRoomPasswordAttempts, roomID, userID)
+}
+
+func MatchQueueKey(gameMode, region string) string {
+	return fmt.Sprintf(KeyMatchQueue, gameMode, region)
+}
+
+func MatchQueuePlayersKey(gameMode string) string {
+	return fmt.Sprintf(KeyMatchQueuePlayers, gameMode)
+}
+
+func MatchQueueTimeKey(gameMode string) string {
+	return fmt.Sprintf(KeyMatchQueueTime, gameMode)
+}
+
+func MatchQueueMembersKey(gameMode string) string {
+	return fmt.Sprintf(KeyMatchQueueMembers, gameMode)
+}
+
+func MatchReadyKey(matchID string) string {
+	return fmt.Sprintf(KeyMatchReady, matchID)
+}
+
+func MatchReadyTimersKey() string {
+	return KeyMatchReadyTimers
+}
+
+func MatchReadyPlayerKey(userID uint64) string {
+	return fmt.Sprintf(KeyMatchReadyPlayer, userID)
+}
+
+func MatchCooldownKey(userID uint64) string {
+	return fmt.Sprintf(KeyMatchCooldown, userID)
+}
+
+func MatchPriorityKey(userID uint64) string {
+	return fmt.Sprintf(KeyMatchPriority, userID)
+}
+
+func MatchBackfillKey(gameMode string) string {
+	return fmt.Sprintf(KeyMatchBackfill, gameMode)
+}
+
+func MatchInstancesKey() string {
+	return KeyMatchInstances
+}
+
+func MatchLeaseKey(bucket string) string {
+	return fmt.Sprintf(KeyMatchLease, bucket)
+}
+
+func MatchLeaseTokenKey(bucket string) string {
+	return fmt.Sprintf(KeyMatchLeaseToken, bucket)
+}
+
+func MatchThroughputKey(gameMode, region string, band int) string {
+	return fmt.Sprintf(KeyMatchThroughput, gameMode, region, band)
 }
 
 func LeaderboardKey(leaderboardType string) string {
 	return fmt.Sprintf(KeyLeaderboard, leaderboardType)
 }
+
+// LeaderboardName 排行榜缓存名称，与 leaderboards 表的唯一键一致，依次拼接类型、模式、赛季和区域中非空的部分
+func LeaderboardName(leaderboardType, gameMode, season, region string) string {
+	name := leaderboardType
+	for _, part := range []string{gameMode, season, region} {
+		if part != "" {
+			name += ":" + part
+		}
+	}
+	return name
+}
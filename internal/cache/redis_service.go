@@ -66,6 +66,10 @@ func (r *redisService) HDel(ctx context.Context, key string, fields ...string) e
 	return r.client.client.HDel(ctx, key, fields...).Err()
 }
 
+func (r *redisService) HMGet(ctx context.Context, key string, fields ...string) ([]interface{}, error) {
+	return r.client.client.HMGet(ctx, key, fields...).Result()
+}
+
 // Set操作实现
 func (r *redisService) SAdd(ctx context.Context, key string, members ...interface{}) error {
 	return r.client.client.SAdd(ctx, key, members...).Err()
@@ -112,6 +116,18 @@ func (r *redisService) ZRevRank(ctx context.Context, key string, member string)
 	return r.client.client.ZRevRank(ctx, key, member).Result()
 }
 
+func (r *redisService) ZRangeByScore(ctx context.Context, key string, opt *redis.ZRangeBy) ([]string, error) {
+	return r.client.client.ZRangeByScore(ctx, key, opt).Result()
+}
+
+func (r *redisService) ZCard(ctx context.Context, key string) (int64, error) {
+	return r.client.client.ZCard(ctx, key).Result()
+}
+
+func (r *redisService) ZRemRangeByScore(ctx context.Context, key, min, max string) (int64, error) {
+	return r.client.client.ZRemRangeByScore(ctx, key, min, max).Result()
+}
+
 // List操作实现
 func (r *redisService) LPush(ctx context.Context, key string, values ...interface{}) error {
 	return r.client.client.LPush(ctx, key, values...).Err()
@@ -133,6 +149,11 @@ func (r *redisService) LLen(ctx context.Context, key string) (int64, error) {
 	return r.client.client.LLen(ctx, key).Result()
 }
 
+// 脚本操作实现
+func (r *redisService) Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	return r.client.client.Eval(ctx, script, keys, args...).Result()
+}
+
 // 分布式锁实现
 func (r *redisService) Lock(ctx context.Context, key string, expiration time.Duration) (bool, error) {
 	result, err := r.client.client.SetNX(ctx, key, "locked", expiration).Result()
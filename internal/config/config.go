@@ -1,6 +1,9 @@
 package config
 
 import (
+	"sort"
+	"time"
+
 	"github.com/spf13/viper"
 )
 
@@ -12,6 +15,7 @@ type Config struct {
 	Auth       AuthConfig       `mapstructure:"auth"`
 	Monitoring MonitoringConfig `mapstructure:"monitoring"`
 	Match      MatchConfig      `mapstructure:"match"`
+	Room       RoomConfig       `mapstructure:"room"`
 }
 
 type ServerConfig struct {
@@ -109,9 +113,228 @@ type HealthConfig struct {
 
 type MatchConfig struct {
 	DefaultAlgorithm string                     `mapstructure:"default_algorithm"`
+	Queue            QueueConfig                `mapstructure:"queue"`
+	Modes            map[string]ModeConfig      `mapstructure:"modes"`         // 各游戏模式配置
+	Regions          map[string]RegionConfig    `mapstructure:"regions"`       // 匹配区域
+	Experiment       ExperimentConfig           `mapstructure:"experiment"`    // 算法A/B实验
+	ReadyCheck       ReadyCheckConfig           `mapstructure:"ready_check"`   // 就绪确认
+	WaitEstimate     WaitEstimateConfig         `mapstructure:"wait_estimate"` // 预计等待时间
+	Batch            BatchConfig                `mapstructure:"batch"`         // 批量匹配
+	Sharding         ShardingConfig             `mapstructure:"sharding"`      // 多实例分片
+	Calibration      CalibrationConfig          `mapstructure:"calibration"`   // 预测校准任务
+	Decay            DecayConfig                `mapstructure:"decay"`         // 不活跃玩家分数衰减
+	Priority         PriorityConfig             `mapstructure:"priority"`      // 排队优先级与低优先级队列
 	Algorithms       map[string]AlgorithmConfig `mapstructure:"algorithms"`
 }
 
+// 算法A/B实验配置，按用户ID分桶，部分玩家使用挑战者算法匹配
+type ExperimentConfig struct {
+	Enabled        bool    `mapstructure:"enabled"`
+	Challenger     string  `mapstructure:"challenger"`      // 挑战者算法名称
+	TrafficPercent float64 `mapstructure:"traffic_percent"` // 进入挑战者组的玩家比例（0-100）
+	Salt           string  `mapstructure:"salt"`            // 分桶盐值，更换后玩家重新分组
+}
+
+// 匹配方式
+const (
+	MatcherGreedy = "greedy" // 按排队顺序逐个玩家寻找当前最佳对局
+	MatcherBatch  = "batch"  // 每轮对整批玩家全局优化，使对局质量总和最大
+)
+
+// 游戏模式配置
+type ModeConfig struct {
+	TeamSize     int                `mapstructure:"team_size"`     // 每队人数，1为单人对战
+	Roles        []string           `mapstructure:"roles"`         // 每队必须各有一名的角色，数量与 team_size 一致，为空时不分角色
+	SearchWindow []SearchWindowStep `mapstructure:"search_window"` // 搜索窗口随排队时间扩大的阶梯，按 after 升序
+	Matcher      string             `mapstructure:"matcher"`       // 匹配方式 greedy/batch，默认 greedy
+}
+
+// 批量匹配配置
+type BatchConfig struct {
+	TimeBudget    int `mapstructure:"time_budget"`    // 每轮优化的时间预算（毫秒）
+	MaxIterations int `mapstructure:"max_iterations"` // 每轮优化的迭代上限，0为只受时间预算限制
+}
+
+// 搜索窗口阶梯：排队达到 After 秒后使用的窗口
+type SearchWindowStep struct {
+	After      int     `mapstructure:"after"`       // 生效的排队时长（秒）
+	MMRDelta   float64 `mapstructure:"mmr_delta"`   // 可接受的MMR差（±）
+	LevelDelta int     `mapstructure:"level_delta"` // 可接受的等级差，0为不限制
+	MaxPing    int     `mapstructure:"max_ping"`    // 可接受的对局延迟上限（毫秒），0为不限制
+}
+
+// 就绪确认配置
+type ReadyCheckConfig struct {
+	Timeout         int `mapstructure:"timeout"`          // 确认超时时间（秒）
+	DeclineCooldown int `mapstructure:"decline_cooldown"` // 拒绝或超时未确认后的排队冷却（秒）
+}
+
+// 预计等待时间配置
+type WaitEstimateConfig struct {
+	Window     int     `mapstructure:"window"`      // 统计近期成局的时间窗口（秒）
+	BandWidth  float64 `mapstructure:"band_width"`  // MMR分段宽度
+	MinSamples int     `mapstructure:"min_samples"` // 分段样本不足时合并相邻分段
+	Refresh    int     `mapstructure:"refresh"`     // 分段统计的刷新间隔（秒）
+}
+
+// 多实例分片配置：匹配桶（模式、区域、MMR段）由各实例通过Redis租约分别负责
+type ShardingConfig struct {
+	Enabled    bool   `mapstructure:"enabled"`
+	InstanceID string `mapstructure:"instance_id"` // 实例标识，为空时使用主机名加随机后缀
+	LeaseTTL   int    `mapstructure:"lease_ttl"`   // 租约有效期（秒），实例失联后最迟该时间后由其他实例接管
+}
+
+// 预测校准任务配置
+type CalibrationConfig struct {
+	Enabled    bool `mapstructure:"enabled"`
+	Interval   int  `mapstructure:"interval"`    // 任务执行间隔（秒）
+	Window     int  `mapstructure:"window"`      // 统计最近多久成局的对局（秒）
+	Bins       int  `mapstructure:"bins"`        // 可靠性曲线分桶数
+	MinSamples int  `mapstructure:"min_samples"` // 样本数不足的算法版本不保存结果
+}
+
+// 不活跃玩家分数衰减配置
+type DecayConfig struct {
+	Enabled          bool                       `mapstructure:"enabled"`
+	Interval         int                        `mapstructure:"interval"`          // 任务执行间隔（秒）
+	BatchSize        int                        `mapstructure:"batch_size"`        // 每次查询的排行榜条目数
+	LeaderboardTypes []string                   `mapstructure:"leaderboard_types"` // 参与衰减的排行榜类型
+	Season           string                     `mapstructure:"season"`            // 当前赛季，赛季排行榜只衰减该赛季的条目，为空时不衰减赛季排行榜
+	Tiers            map[string]DecayTierConfig `mapstructure:"tiers"`             // 参与衰减的段位，未配置的段位不衰减
+	InitialRD        float64                    `mapstructure:"initial_rd"`        // 条目没有RD时视为该值
+	RDPerDay         float64                    `mapstructure:"rd_per_day"`        // 每天不活跃RD的增长量
+	MaxRD            float64                    `mapstructure:"max_rd"`            // RD上限
+	InitialSigma     float64                    `mapstructure:"initial_sigma"`     // 条目没有sigma时视为该值
+	SigmaPerDay      float64                    `mapstructure:"sigma_per_day"`     // 每天不活跃sigma的增长量
+	MaxSigma         float64                    `mapstructure:"max_sigma"`         // sigma上限
+}
+
+// 段位衰减配置
+type DecayTierConfig struct {
+	InactiveDays int   `mapstructure:"inactive_days"`  // 超过多少天未对局开始衰减
+	PointsPerDay int64 `mapstructure:"points_per_day"` // 每天衰减的分数
+	Floor        int64 `mapstructure:"floor"`          // 衰减下限
+}
+
+// 自定义房间配置
+type RoomConfig struct {
+	InviteTTL             int    `mapstructure:"invite_ttl"`              // 邀请令牌有效期（秒）
+	InviteBaseURL         string `mapstructure:"invite_base_url"`         // 邀请链接前缀，链接为 {invite_base_url}/{room_code}?invite={token}
+	PasswordMinLength     int    `mapstructure:"password_min_length"`     // 房间密码最小长度
+	PasswordMaxLength     int    `mapstructure:"password_max_length"`     // 房间密码最大长度，bcrypt 只使用前72字节
+	PasswordMaxAttempts   int    `mapstructure:"password_max_attempts"`   // 时间窗口内每个房间允许尝试密码的次数
+	PasswordAttemptWindow int    `mapstructure:"password_attempt_window"` // 尝试次数的统计窗口（秒）
+}
+
+// 排队优先级配置，评分保存在队列条目中，对应 match_queue.priority_score
+type PriorityConfig struct {
+	Enabled            bool              `mapstructure:"enabled"`
+	MaxScore           float64           `mapstructure:"max_score"`             // 优先级评分上限
+	DodgeBonus         float64           `mapstructure:"dodge_bonus"`           // 对局因其他玩家拒绝或未确认而取消时，已确认玩家增加的评分
+	WaitBonusPerMinute float64           `mapstructure:"wait_bonus_per_minute"` // 排队超时未成局时，每等待一分钟为下次排队增加的评分
+	CarryOver          int               `mapstructure:"carry_over"`            // 超时未成局留下的评分保留时间（秒）
+	LowPriority        LowPriorityConfig `mapstructure:"low_priority"`          // 近期弃赛玩家的低优先级队列
+}
+
+// 低优先级队列配置：惩罚期内弃赛次数达到阈值的玩家只能与同样处于低优先级队列的玩家匹配
+type LowPriorityConfig struct {
+	Enabled          bool `mapstructure:"enabled"`
+	PenaltyPeriod    int  `mapstructure:"penalty_period"`    // 惩罚期（秒），统计该时间内的弃赛
+	AbandonThreshold int  `mapstructure:"abandon_threshold"` // 惩罚期内弃赛达到该次数进入低优先级队列
+	ExtraWait        int  `mapstructure:"extra_wait"`        // 入队后额外等待的时间（秒），期间不参与匹配
+}
+
+// 匹配区域配置
+type RegionConfig struct {
+	DataCenters []string `mapstructure:"data_centers"` // 区域内的机房
+	Neighbors   []string `mapstructure:"neighbors"`    // 相邻区域，排队超过 cross_region_wait 后放宽到这些区域
+}
+
+// 匹配队列配置
+type QueueConfig struct {
+	GameModes      []string `mapstructure:"game_modes"`      // 开放匹配的游戏模式
+	Timeout        int      `mapstructure:"timeout"`         // 排队超时时间（秒）
+	MMRWindow      float64  `mapstructure:"mmr_window"`      // 未配置搜索窗口的模式使用的MMR窗口（±）
+	CandidateLimit int      `mapstructure:"candidate_limit"` // 单次获取候选玩家上限
+	MaxPartySize   int      `mapstructure:"max_party_size"`  // 预组队最大人数
+	PartyMMRBonus  float64  `mapstructure:"party_mmr_bonus"` // 预组队每多一人的MMR加成
+
+	DefaultRegion   string `mapstructure:"default_region"`    // 未上报区域的玩家所在区域
+	CrossRegionWait int    `mapstructure:"cross_region_wait"` // 排队多久后放宽到相邻区域（秒）
+	MaxPing         int    `mapstructure:"max_ping"`          // 未配置搜索窗口的模式使用的延迟上限（毫秒），0为不限制
+}
+
+// TeamSize 获取游戏模式的每队人数，未配置时为单人对战
+func (m *MatchConfig) TeamSize(gameMode string) int {
+	if mode, ok := m.Modes[gameMode]; ok && mode.TeamSize > 0 {
+		return mode.TeamSize
+	}
+	return 1
+}
+
+// Roles 获取游戏模式的角色列表，不分角色的模式返回空
+func (m *MatchConfig) Roles(gameMode string) []string {
+	return m.Modes[gameMode].Roles
+}
+
+// Matcher 获取游戏模式的匹配方式，未配置或无法识别时为 greedy
+func (m *MatchConfig) Matcher(gameMode string) string {
+	if m.Modes[gameMode].Matcher == MatcherBatch {
+		return MatcherBatch
+	}
+	return MatcherGreedy
+}
+
+// SearchWindow 获取排队 waited 时长后的搜索窗口
+// 模式未配置阶梯时使用队列的固定MMR窗口和延迟上限
+func (m *MatchConfig) SearchWindow(gameMode string, waited time.Duration) SearchWindowStep {
+	window := SearchWindowStep{MMRDelta: m.Queue.MMRWindow, MaxPing: m.Queue.MaxPing}
+	for _, step := range m.Modes[gameMode].SearchWindow {
+		if waited < time.Duration(step.After)*time.Second {
+			break
+		}
+		window = step
+	}
+	return window
+}
+
+// RegionNames 所有匹配区域，未配置区域时只有默认区域
+func (m *MatchConfig) RegionNames() []string {
+	if len(m.Regions) == 0 {
+		return []string{m.Queue.DefaultRegion}
+	}
+	names := make([]string, 0, len(m.Regions))
+	for name := range m.Regions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// HasRegion 区域是否可用于匹配
+func (m *MatchConfig) HasRegion(region string) bool {
+	if len(m.Regions) == 0 {
+		return region == m.Queue.DefaultRegion
+	}
+	_, ok := m.Regions[region]
+	return ok
+}
+
+// DataCenters 返回给定区域内的所有机房（去重，保持顺序）
+func (m *MatchConfig) DataCenters(regions ...string) []string {
+	seen := make(map[string]bool)
+	var dataCenters []string
+	for _, region := range regions {
+		for _, dc := range m.Regions[region].DataCenters {
+			if !seen[dc] {
+				seen[dc] = true
+				dataCenters = append(dataCenters, dc)
+			}
+		}
+	}
+	return dataCenters
+}
+
 type AlgorithmConfig struct {
 	Name        string                 `mapstructure:"name"`
 	Description string                 `mapstructure:"description"`
@@ -125,11 +348,6 @@ type AlgorithmConfig struct {
 	MaxLevelDiff   int     `mapstructure:"max_level_diff"`    //最大等级差
 	MaxWinRateDiff float64 `mapstructure:"max_win_rate_diff"` //最大胜率差
 	MaxPingDiff    int     `mapstructure:"max_ping_diff"`     //最大延迟差
-	MaxQueueTime   int     `mapstructure:"max_queue_time"`    //最大排队时间 秒
-
-	//动态调整
-	EnableDynamicAdjustment bool    `mapstructure:"enable_dynamic_adjustment"` //是否启用动态调整
-	QueueTimeMultiplier     float64 `mapstructure:"queue_time_multiplier"`     //排队时间倍率
 }
 
 func Load() (*Config, error) {
@@ -184,6 +402,61 @@ func Load() (*Config, error) {
 	viper.SetDefault("monitoring.health.path", "/health")
 	viper.SetDefault("monitoring.health.check_interval", 30)
 
+	// 匹配队列默认值
+	viper.SetDefault("match.queue.game_modes", []string{"classic", "ranked", "casual", "tournament"})
+	viper.SetDefault("match.queue.timeout", 300) // 5分钟
+	viper.SetDefault("match.queue.mmr_window", 200)
+	viper.SetDefault("match.queue.candidate_limit", 100)
+	viper.SetDefault("match.queue.max_party_size", 5)
+	viper.SetDefault("match.queue.party_mmr_bonus", 25)
+	viper.SetDefault("match.queue.default_region", "default")
+	viper.SetDefault("match.queue.cross_region_wait", 60) // 1分钟
+	viper.SetDefault("match.queue.max_ping", 150)
+	viper.SetDefault("match.ready_check.timeout", 15)
+	viper.SetDefault("match.ready_check.decline_cooldown", 120) // 2分钟
+	viper.SetDefault("match.wait_estimate.window", 600)         // 10分钟
+	viper.SetDefault("match.wait_estimate.band_width", 200)
+	viper.SetDefault("match.wait_estimate.min_samples", 5)
+	viper.SetDefault("match.wait_estimate.refresh", 10)
+	viper.SetDefault("match.batch.time_budget", 200)
+	viper.SetDefault("match.batch.max_iterations", 0)
+	viper.SetDefault("match.sharding.enabled", true)
+	viper.SetDefault("match.sharding.lease_ttl", 10)
+	viper.SetDefault("match.calibration.enabled", true)
+	viper.SetDefault("match.calibration.interval", 3600) // 1小时
+	viper.SetDefault("match.calibration.window", 604800) // 7天
+	viper.SetDefault("match.calibration.bins", 10)
+	viper.SetDefault("match.calibration.min_samples", 50)
+	viper.SetDefault("match.decay.enabled", false)
+	viper.SetDefault("match.decay.interval", 86400) // 1天
+	viper.SetDefault("match.decay.batch_size", 500)
+	viper.SetDefault("match.decay.leaderboard_types", []string{"global", "seasonal", "mode_specific"})
+	viper.SetDefault("match.decay.initial_rd", 350)
+	viper.SetDefault("match.decay.rd_per_day", 5)
+	viper.SetDefault("match.decay.max_rd", 350)
+	viper.SetDefault("match.decay.initial_sigma", 8.333)
+	viper.SetDefault("match.decay.sigma_per_day", 0.12)
+	viper.SetDefault("match.decay.max_sigma", 8.333)
+	viper.SetDefault("match.priority.enabled", true)
+	viper.SetDefault("match.priority.max_score", 100)
+	viper.SetDefault("match.priority.dodge_bonus", 20)
+	viper.SetDefault("match.priority.wait_bonus_per_minute", 2)
+	viper.SetDefault("match.priority.carry_over", 900) // 15分钟
+	viper.SetDefault("match.priority.low_priority.enabled", true)
+	viper.SetDefault("match.priority.low_priority.penalty_period", 86400) // 1天
+	viper.SetDefault("match.priority.low_priority.abandon_threshold", 1)
+	viper.SetDefault("match.priority.low_priority.extra_wait", 120) // 2分钟
+	viper.SetDefault("match.experiment.enabled", false)
+	viper.SetDefault("match.experiment.traffic_percent", 10)
+
+	// 房间默认值
+	viper.SetDefault("room.invite_ttl", 600) // 10分钟
+	viper.SetDefault("room.invite_base_url", "http://localhost:8080/rooms")
+	viper.SetDefault("room.password_min_length", 4)
+	viper.SetDefault("room.password_max_length", 64)
+	viper.SetDefault("room.password_max_attempts", 5)
+	viper.SetDefault("room.password_attempt_window", 300) // 5分钟
+
 	viper.SetConfigName("config")
 	viper.SetConfigType("yaml")
 	viper.AddConfigPath("./configs")
@@ -28,6 +28,12 @@ type Metrics struct {
 	MatchesTotal   prometheus.Counter
 	MatchDuration  prometheus.Histogram
 
+	// 匹配预测校准指标，按算法版本
+	PredictionBrierScore  *prometheus.GaugeVec
+	PredictionLogLoss     *prometheus.GaugeVec
+	PredictionSamples     *prometheus.GaugeVec
+	PredictionReliability *prometheus.GaugeVec
+
 	// 系统指标
 	DatabaseConnections prometheus.Gauge
 	RedisConnections    prometheus.Gauge
@@ -123,6 +129,38 @@ func NewMetrics(config *config.MetricsConfig) *Metrics {
 				Buckets:   []float64{1, 5, 10, 30, 60, 300, 600, 1800, 3600},
 			},
 		),
+		PredictionBrierScore: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "match_prediction_brier_score",
+				Help:      "Brier score of match win predictions",
+			}, []string{"algorithm_version"},
+		),
+		PredictionLogLoss: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "match_prediction_log_loss",
+				Help:      "Log loss of match win predictions",
+			}, []string{"algorithm_version"},
+		),
+		PredictionSamples: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "match_prediction_samples",
+				Help:      "Number of team predictions in the calibration window",
+			}, []string{"algorithm_version"},
+		),
+		PredictionReliability: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "match_prediction_reliability",
+				Help:      "Observed win rate of predictions in each probability bin",
+			}, []string{"algorithm_version", "bin"},
+		),
 		DatabaseConnections: prometheus.NewGauge(
 			prometheus.GaugeOpts{
 				Namespace: namespace,
@@ -168,6 +206,10 @@ func NewMetrics(config *config.MetricsConfig) *Metrics {
 		m.GameRoomsTotal,
 		m.MatchesTotal,
 		m.MatchDuration,
+		m.PredictionBrierScore,
+		m.PredictionLogLoss,
+		m.PredictionSamples,
+		m.PredictionReliability,
 		m.DatabaseConnections,
 		m.RedisConnections,
 		m.MemoryUsage,
@@ -238,3 +280,15 @@ func (m *Metrics) IncMatchesTotal() {
 func (m *Metrics) RecordMatchDuration(duration time.Duration) {
 	m.MatchDuration.Observe(duration.Seconds())
 }
+
+// 记录算法版本的预测校准结果
+func (m *Metrics) RecordPredictionCalibration(version string, samples int, brierScore, logLoss float64) {
+	m.PredictionBrierScore.WithLabelValues(version).Set(brierScore)
+	m.PredictionLogLoss.WithLabelValues(version).Set(logLoss)
+	m.PredictionSamples.WithLabelValues(version).Set(float64(samples))
+}
+
+// 记录可靠性曲线一个分桶的实际胜率，bin 为分桶下界
+func (m *Metrics) RecordPredictionReliability(version, bin string, observedRate float64) {
+	m.PredictionReliability.WithLabelValues(version, bin).Set(observedRate)
+}
@@ -6,6 +6,22 @@ import (
 	"gorm.io/gorm"
 )
 
+// 房间状态，只能按 waiting -> starting -> in_progress -> finished 推进，未结束的房间可以取消
+const (
+	RoomStatusWaiting    = "waiting"
+	RoomStatusStarting   = "starting"
+	RoomStatusInProgress = "in_progress"
+	RoomStatusFinished   = "finished"
+	RoomStatusCancelled  = "cancelled"
+)
+
+// 房间队伍
+const (
+	RoomTeamA         = "team_a"
+	RoomTeamB         = "team_b"
+	RoomTeamSpectator = "spectator"
+)
+
 type GameRoom struct {
 	ID             uint64         `json:"id" gorm:"primaryKey"`
 	RoomCode       string         `json:"room_code" gorm:"uniqueIndex;size:20;not null"`
@@ -14,7 +30,12 @@ type GameRoom struct {
 	CurrentPlayers int            `json:"current_players" gorm:"default:0"`
 	Status         string         `json:"status" gorm:"size:20;default:'waiting'"`
 	GameMode       string         `json:"game_mode" gorm:"size:50;not null"`
+	MapName        string         `json:"map_name" gorm:"size:50;default:'default_map'"`
+	IsPrivate      bool           `json:"is_private" gorm:"default:false"`
+	PasswordHash   *string        `json:"-" gorm:"size:255"` // 私人房间密码，为空时只能通过邀请加入
 	CreatedBy      uint64         `json:"created_by" gorm:"not null;index"`
+	StartedAt      *time.Time     `json:"started_at"`
+	EndedAt        *time.Time     `json:"ended_at"`
 	CreatedAt      time.Time      `json:"created_at"`
 	UpdatedAt      time.Time      `json:"updated_at"`
 	DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
@@ -25,13 +46,17 @@ type GameRoom struct {
 	Records []GameRecord `json:"records,omitempty"`
 }
 
+// 房间玩家，离开房间后保留记录并释放位置，重新加入时复用该记录
 type RoomPlayer struct {
-	ID       uint64    `json:"id" gorm:"primaryKey"`
-	RoomID   uint64    `json:"room_id" gorm:"not null;index"`
-	UserID   uint64    `json:"user_id" gorm:"not null;index"`
-	Team     string    `json:"team" gorm:"size:10"`
-	Position int       `json:"position"`
-	JoinedAt time.Time `json:"joined_at"`
+	ID        uint64     `json:"id" gorm:"primaryKey"`
+	RoomID    uint64     `json:"room_id" gorm:"not null;index"`
+	UserID    uint64     `json:"user_id" gorm:"not null;index"`
+	Team      string     `json:"team" gorm:"size:10"`
+	Position  *int       `json:"position"` // 队伍内位置，离开房间后为空
+	IsReady   bool       `json:"is_ready" gorm:"default:false"`
+	IsCaptain bool       `json:"is_captain" gorm:"default:false"`
+	JoinedAt  time.Time  `json:"joined_at"`
+	LeftAt    *time.Time `json:"left_at"`
 
 	// 关联关系
 	Room GameRoom `json:"room" gorm:"foreignKey:RoomID"`
@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// 赛季排行榜，每个赛季一组条目
+const LeaderboardTypeSeasonal = "seasonal"
+
+// 排行榜分数变化原因
+const (
+	LeaderboardChangeGame  = "game"
+	LeaderboardChangeDecay = "decay"
+)
+
+type Leaderboard struct {
+	ID              uint64     `json:"id" gorm:"primaryKey"`
+	UserID          uint64     `json:"user_id" gorm:"not null;index"`
+	LeaderboardType string     `json:"leaderboard_type" gorm:"size:50;not null"`
+	GameMode        string     `json:"game_mode" gorm:"size:50"`
+	RankPosition    int        `json:"rank_position" gorm:"not null"`
+	Score           int64      `json:"score" gorm:"not null;default:0"`
+	Tier            string     `json:"tier" gorm:"size:20;not null"`
+	Points          int        `json:"points" gorm:"default:0"`
+	Season          string     `json:"season" gorm:"size:20"`
+	Region          string     `json:"region" gorm:"size:10;default:'global'"`
+	RatingDeviation *float64   `json:"rating_deviation" gorm:"type:decimal(7,2)"` // 评级偏差（Glicko RD），为空时视为初始值
+	Sigma           *float64   `json:"sigma" gorm:"type:decimal(6,3)"`            // 技能标准差（TrueSkill），为空时视为初始值
+	LastDecayAt     *time.Time `json:"last_decay_at"`                             // 最近一次衰减计算到的时间
+	LastUpdated     time.Time  `json:"last_updated"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+type LeaderboardHistory struct {
+	ID              uint64    `json:"id" gorm:"primaryKey"`
+	UserID          uint64    `json:"user_id" gorm:"not null;index"`
+	LeaderboardType string    `json:"leaderboard_type" gorm:"size:50;not null"`
+	OldRank         int       `json:"old_rank"`
+	NewRank         int       `json:"new_rank"`
+	OldScore        int64     `json:"old_score"`
+	NewScore        int64     `json:"new_score"`
+	ChangeReason    string    `json:"change_reason" gorm:"size:100"`
+	GameRecordID    *uint64   `json:"game_record_id"`
+	CreatedAt       time.Time `json:"created_at" gorm:"index"`
+}
+
+func (Leaderboard) TableName() string {
+	return "leaderboards"
+}
+
+func (LeaderboardHistory) TableName() string {
+	return "leaderboard_history"
+}
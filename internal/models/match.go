@@ -0,0 +1,84 @@
+package models
+
+import "time"
+
+// 匹配记录状态
+const (
+	MatchStatusCompleted = "completed"
+	MatchStatusFailed    = "failed"
+	MatchStatusCancelled = "cancelled"
+)
+
+type MatchRecord struct {
+	ID               uint64    `json:"id" gorm:"primaryKey"`
+	MatchID          string    `json:"match_id" gorm:"uniqueIndex;size:50;not null"`
+	GameMode         string    `json:"game_mode" gorm:"size:50;not null;index"`
+	PlayerCount      int       `json:"player_count" gorm:"not null"`
+	AvgRank          string    `json:"avg_rank" gorm:"size:20"`
+	AvgWaitTime      int       `json:"avg_wait_time"` // 平均等待时间（秒）
+	MatchQuality     float64   `json:"match_quality" gorm:"type:decimal(3,2)"`
+	Fairness         *float64  `json:"fairness" gorm:"type:decimal(5,4)"` // 预测公平度，没有预测时为空
+	AlgorithmVersion string    `json:"algorithm_version" gorm:"size:20"`
+	RoomID           *uint64   `json:"room_id"`
+	Status           string    `json:"status" gorm:"size:20;default:'completed'"`
+	CreatedAt        time.Time `json:"created_at" gorm:"index"`
+
+	// 关联关系
+	Players []MatchPlayer `json:"players,omitempty" gorm:"foreignKey:MatchRecordID"`
+}
+
+type MatchPlayer struct {
+	ID             uint64    `json:"id" gorm:"primaryKey"`
+	MatchRecordID  uint64    `json:"match_record_id" gorm:"not null;index"`
+	UserID         uint64    `json:"user_id" gorm:"not null;index"`
+	QueueTime      int       `json:"queue_time" gorm:"not null"` // 排队时间（秒）
+	Rank           string    `json:"rank" gorm:"size:20;not null"`
+	WinRate        float64   `json:"win_rate" gorm:"type:decimal(5,4)"`
+	TeamAssignment string    `json:"team_assignment" gorm:"size:10"`
+	WinProbability *float64  `json:"win_probability" gorm:"type:decimal(5,4)"` // 预测的所在队伍获胜概率
+	ExpectedGain   *float64  `json:"expected_gain" gorm:"type:decimal(8,2)"`   // 获胜时的预期MMR变化
+	ExpectedLoss   *float64  `json:"expected_loss" gorm:"type:decimal(8,2)"`   // 失败时的预期MMR变化
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// 算法版本在一个时间窗口内的预测校准结果
+type AlgorithmCalibration struct {
+	ID               uint64    `json:"id" gorm:"primaryKey"`
+	AlgorithmVersion string    `json:"algorithm_version" gorm:"size:20;not null;index"`
+	WindowStart      time.Time `json:"window_start" gorm:"not null"`
+	WindowEnd        time.Time `json:"window_end" gorm:"not null"`
+	SampleCount      int       `json:"sample_count" gorm:"not null"`
+	BrierScore       float64   `json:"brier_score" gorm:"type:decimal(6,5)"`
+	LogLoss          float64   `json:"log_loss" gorm:"type:decimal(8,5)"`
+	CreatedAt        time.Time `json:"created_at" gorm:"index"`
+
+	// 关联关系
+	Bins []CalibrationBin `json:"bins,omitempty" gorm:"foreignKey:CalibrationID"`
+}
+
+// 可靠性曲线的一个分桶
+type CalibrationBin struct {
+	ID            uint64  `json:"id" gorm:"primaryKey"`
+	CalibrationID uint64  `json:"calibration_id" gorm:"not null;index"`
+	LowerBound    float64 `json:"lower_bound" gorm:"type:decimal(3,2)"`
+	UpperBound    float64 `json:"upper_bound" gorm:"type:decimal(3,2)"`
+	SampleCount   int     `json:"sample_count" gorm:"not null"`
+	MeanPredicted float64 `json:"mean_predicted" gorm:"type:decimal(5,4)"` // 桶内平均预测概率
+	ObservedRate  float64 `json:"observed_rate" gorm:"type:decimal(5,4)"`  // 桶内实际胜率
+}
+
+func (MatchRecord) TableName() string {
+	return "match_records"
+}
+
+func (MatchPlayer) TableName() string {
+	return "match_players"
+}
+
+func (AlgorithmCalibration) TableName() string {
+	return "algorithm_calibrations"
+}
+
+func (CalibrationBin) TableName() string {
+	return "calibration_bins"
+}
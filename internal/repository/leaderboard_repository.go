@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/mangooer/gamehub-arena/internal/database"
+	"github.com/mangooer/gamehub-arena/internal/models"
+	"gorm.io/gorm"
+)
+
+var ErrLeaderboardChanged = errors.New("leaderboard entry changed concurrently")
+
+type LeaderboardRepository struct {
+	db *database.Database
+}
+
+func NewLeaderboardRepository(db *database.Database) *LeaderboardRepository {
+	return &LeaderboardRepository{db: db}
+}
+
+// 待衰减的排行榜条目及玩家最近一次对局时间
+type DecayCandidate struct {
+	models.Leaderboard
+	LastPlayedAt time.Time `json:"last_played_at"`
+}
+
+// 获取指定段位中分数高于 floor、且 inactiveBefore 之后没有对局的条目，按ID升序分页
+// 任意模式的对局都视为活跃，从未对局的玩家以条目创建时间为准；赛季排行榜只包含 season 赛季的条目
+func (r *LeaderboardRepository) ListDecayCandidates(tier string, leaderboardTypes []string, season string, floor int64, inactiveBefore time.Time, afterID uint64, limit int) ([]DecayCandidate, error) {
+	var candidates []DecayCandidate
+	err := r.db.GetDB().
+		Table("leaderboards AS l").
+		Select("l.*, COALESCE(MAX(pgs.created_at), l.created_at) AS last_played_at").
+		Joins("LEFT JOIN player_game_stats pgs ON pgs.user_id = l.user_id").
+		Where("LOWER(l.tier) = LOWER(?) AND l.leaderboard_type IN ? AND l.score > ? AND l.id > ?", tier, leaderboardTypes, floor, afterID).
+		Where("(l.leaderboard_type <> ? OR l.season = ?)", models.LeaderboardTypeSeasonal, season).
+		Group("l.id").
+		Having("COALESCE(MAX(pgs.created_at), l.created_at) < ?", inactiveBefore).
+		Order("l.id ASC").
+		Limit(limit).
+		Scan(&candidates).Error
+	if err != nil {
+		return nil, err
+	}
+	return candidates, nil
+}
+
+// 保存衰减结果并写入排行榜历史，分数已被其他流程修改时返回 ErrLeaderboardChanged
+// 按新分数重新计算条目在所属排行榜中的名次，被越过的条目名次依次上移，history.NewRank 为新名次
+func (r *LeaderboardRepository) ApplyDecay(entry *models.Leaderboard, oldScore int64, history *models.LeaderboardHistory) error {
+	return r.db.GetDB().Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Leaderboard{}).
+			Where("id = ? AND score = ?", entry.ID, oldScore).
+			Updates(map[string]interface{}{
+				"score":            entry.Score,
+				"tier":             entry.Tier,
+				"rating_deviation": entry.RatingDeviation,
+				"sigma":            entry.Sigma,
+				"last_decay_at":    entry.LastDecayAt,
+				"last_updated":     entry.LastUpdated,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrLeaderboardChanged
+		}
+
+		var higher int64
+		if err := sameBoard(tx.Model(&models.Leaderboard{}), entry).
+			Where("id <> ? AND score > ?", entry.ID, entry.Score).
+			Count(&higher).Error; err != nil {
+			return err
+		}
+		oldRank, newRank := entry.RankPosition, int(higher)+1
+		if oldRank > 0 && newRank > oldRank {
+			err := sameBoard(tx.Model(&models.Leaderboard{}), entry).
+				Where("id <> ? AND rank_position > ? AND rank_position <= ?", entry.ID, oldRank, newRank).
+				Update("rank_position", gorm.Expr("rank_position - 1")).Error
+			if err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&models.Leaderboard{}).Where("id = ?", entry.ID).Update("rank_position", newRank).Error; err != nil {
+			return err
+		}
+		entry.RankPosition = newRank
+		history.NewRank = newRank
+		return tx.Create(history).Error
+	})
+}
+
+// 与条目属于同一排行榜（类型、模式、赛季、区域相同）的条目，空值与空字符串视为相同
+func sameBoard(db *gorm.DB, entry *models.Leaderboard) *gorm.DB {
+	return db.Where("leaderboard_type = ? AND COALESCE(game_mode, '') = ? AND COALESCE(season, '') = ? AND COALESCE(region, '') = ?",
+		entry.LeaderboardType, entry.GameMode, entry.Season, entry.Region)
+}
+
+// 获取用户的排行榜历史，按时间倒序
+func (r *LeaderboardRepository) ListHistory(userID uint64, limit, offset int) ([]models.LeaderboardHistory, error) {
+	var history []models.LeaderboardHistory
+	err := r.db.GetDB().
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&history).Error
+	if err != nil {
+		return nil, err
+	}
+	return history, nil
+}
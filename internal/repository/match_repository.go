@@ -0,0 +1,206 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mangooer/gamehub-arena/internal/database"
+	"github.com/mangooer/gamehub-arena/internal/models"
+	"gorm.io/gorm"
+)
+
+type MatchRepository struct {
+	db *database.Database
+}
+
+func NewMatchRepository(db *database.Database) *MatchRepository {
+	return &MatchRepository{db: db}
+}
+
+// 按算法版本汇总的匹配统计
+type AlgorithmVersionStats struct {
+	AlgorithmVersion string  `json:"algorithm_version"`
+	MatchCount       int64   `json:"match_count"`
+	AvgQuality       float64 `json:"avg_quality"`
+	AvgWaitTime      float64 `json:"avg_wait_time"`
+}
+
+// 一支队伍在成局时的预测获胜概率及实际结果
+type PredictionOutcome struct {
+	AlgorithmVersion string  `json:"algorithm_version"`
+	MatchRecordID    uint64  `json:"match_record_id"`
+	Team             string  `json:"team"`
+	WinProbability   float64 `json:"win_probability"`
+	Won              bool    `json:"won"`
+}
+
+// 创建匹配记录，记录与所有玩家在同一事务中写入
+func (r *MatchRepository) CreateMatch(record *models.MatchRecord) error {
+	if len(record.Players) == 0 {
+		return fmt.Errorf("match %s has no players", record.MatchID)
+	}
+	return r.db.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Players").Create(record).Error; err != nil {
+			return err
+		}
+		for i := range record.Players {
+			record.Players[i].MatchRecordID = record.ID
+		}
+		return tx.Create(&record.Players).Error
+	})
+}
+
+// 根据匹配ID获取记录
+func (r *MatchRepository) GetByMatchID(matchID string) (*models.MatchRecord, error) {
+	var record models.MatchRecord
+	if err := r.db.GetDB().Where("match_id = ?", matchID).Preload("Players").First(&record).Error; err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// 获取用户参与的匹配记录，按时间倒序
+func (r *MatchRepository) ListByUser(userID uint64, limit, offset int) ([]models.MatchRecord, error) {
+	var records []models.MatchRecord
+	err := r.db.GetDB().
+		Where("id IN (?)", r.db.GetDB().Model(&models.MatchPlayer{}).Select("match_record_id").Where("user_id = ?", userID)).
+		Preload("Players").
+		Order("created_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&records).Error
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// 获取时间范围内的匹配记录 [start, end)
+func (r *MatchRepository) ListByTimeRange(start, end time.Time, limit, offset int) ([]models.MatchRecord, error) {
+	var records []models.MatchRecord
+	err := r.db.GetDB().
+		Where("created_at >= ? AND created_at < ?", start, end).
+		Preload("Players").
+		Order("created_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&records).Error
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// 获取指定算法版本在时间范围内的匹配记录 [start, end)
+func (r *MatchRepository) ListByAlgorithmVersion(version string, start, end time.Time, limit, offset int) ([]models.MatchRecord, error) {
+	var records []models.MatchRecord
+	err := r.db.GetDB().
+		Where("algorithm_version = ? AND created_at >= ? AND created_at < ?", version, start, end).
+		Preload("Players").
+		Order("created_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&records).Error
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// 按算法版本汇总时间范围内已完成匹配的数量、平均质量和平均等待时间
+func (r *MatchRepository) GetAlgorithmVersionStats(start, end time.Time) ([]AlgorithmVersionStats, error) {
+	var stats []AlgorithmVersionStats
+	err := r.db.GetDB().
+		Model(&models.MatchRecord{}).
+		Select("algorithm_version, COUNT(*) AS match_count, AVG(match_quality) AS avg_quality, AVG(avg_wait_time) AS avg_wait_time").
+		Where("status = ? AND created_at >= ? AND created_at < ?", models.MatchStatusCompleted, start, end).
+		Group("algorithm_version").
+		Order("algorithm_version").
+		Scan(&stats).Error
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// 获取时间范围内成局 [start, end) 且已有比赛结果的队伍预测
+// 通过房间关联到成局后开始的已完成比赛，按队伍汇总 player_game_stats 的胜负，平局不计入
+func (r *MatchRepository) ListPredictionOutcomes(start, end time.Time) ([]PredictionOutcome, error) {
+	var outcomes []PredictionOutcome
+	err := r.db.GetDB().
+		Table("match_players AS mp").
+		Select("mr.algorithm_version, mp.match_record_id, mp.team_assignment AS team, MAX(mp.win_probability) AS win_probability, BOOL_OR(pgs.is_winner) AS won").
+		Joins("JOIN match_records mr ON mr.id = mp.match_record_id").
+		Joins("JOIN game_records gr ON gr.room_id = mr.room_id AND gr.started_at >= mr.created_at").
+		Joins("JOIN player_game_stats pgs ON pgs.game_record_id = gr.id AND pgs.user_id = mp.user_id").
+		Where("mr.status = ? AND mr.created_at >= ? AND mr.created_at < ?", models.MatchStatusCompleted, start, end).
+		Where("gr.status = 'completed' AND gr.winner_team <> 'draw'").
+		Where("mp.win_probability IS NOT NULL").
+		Group("mr.algorithm_version, mp.match_record_id, mp.team_assignment").
+		Scan(&outcomes).Error
+	if err != nil {
+		return nil, err
+	}
+	return outcomes, nil
+}
+
+// 统计玩家自 since 起本人弃赛的对局数（player_game_stats.abandoned），同局的其他玩家不计入
+func (r *MatchRepository) CountAbandons(userID uint64, since time.Time) (int64, error) {
+	var count int64
+	err := r.db.GetDB().
+		Table("player_game_stats AS pgs").
+		Joins("JOIN game_records gr ON gr.id = pgs.game_record_id").
+		Where("pgs.user_id = ? AND pgs.abandoned AND gr.status = 'abandoned' AND gr.ended_at >= ?", userID, since).
+		Count(&count).Error
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// 保存校准结果，结果与所有分桶在同一事务中写入
+func (r *MatchRepository) CreateCalibration(calibration *models.AlgorithmCalibration) error {
+	return r.db.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Bins").Create(calibration).Error; err != nil {
+			return err
+		}
+		if len(calibration.Bins) == 0 {
+			return nil
+		}
+		for i := range calibration.Bins {
+			calibration.Bins[i].CalibrationID = calibration.ID
+		}
+		return tx.Create(&calibration.Bins).Error
+	})
+}
+
+// 获取每个算法版本最近一次的校准结果
+func (r *MatchRepository) GetLatestCalibrations() ([]models.AlgorithmCalibration, error) {
+	var calibrations []models.AlgorithmCalibration
+	latest := r.db.GetDB().Model(&models.AlgorithmCalibration{}).Select("MAX(id)").Group("algorithm_version")
+	err := r.db.GetDB().
+		Where("id IN (?)", latest).
+		Preload("Bins").
+		Order("algorithm_version").
+		Find(&calibrations).Error
+	if err != nil {
+		return nil, err
+	}
+	return calibrations, nil
+}
+
+// 获取算法版本的校准历史，按时间倒序
+func (r *MatchRepository) ListCalibrations(version string, limit, offset int) ([]models.AlgorithmCalibration, error) {
+	var calibrations []models.AlgorithmCalibration
+	err := r.db.GetDB().
+		Where("algorithm_version = ?", version).
+		Preload("Bins").
+		Order("created_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&calibrations).Error
+	if err != nil {
+		return nil, err
+	}
+	return calibrations, nil
+}
@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"github.com/mangooer/gamehub-arena/internal/database"
+	"github.com/mangooer/gamehub-arena/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RoomRepository struct {
+	db *database.Database
+}
+
+func NewRoomRepository(db *database.Database) *RoomRepository {
+	return &RoomRepository{db: db}
+}
+
+// 在房间行锁内执行的操作，用于修改房间及其玩家
+type RoomTx struct {
+	tx *gorm.DB
+}
+
+// 创建房间，房间与创建者在同一事务中写入
+func (r *RoomRepository) CreateRoom(room *models.GameRoom, creator *models.RoomPlayer) error {
+	return r.db.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(room).Error; err != nil {
+			return err
+		}
+		creator.RoomID = room.ID
+		if err := tx.Omit(clause.Associations).Create(creator).Error; err != nil {
+			return err
+		}
+		room.Players = []models.RoomPlayer{*creator}
+		return nil
+	})
+}
+
+// 根据ID获取房间及房间内的玩家
+func (r *RoomRepository) GetByID(id uint64) (*models.GameRoom, error) {
+	var room models.GameRoom
+	if err := r.db.GetDB().Preload("Players", activePlayers).First(&room, id).Error; err != nil {
+		return nil, err
+	}
+	return &room, nil
+}
+
+// 根据房间码获取房间及房间内的玩家
+func (r *RoomRepository) GetByCode(roomCode string) (*models.GameRoom, error) {
+	var room models.GameRoom
+	if err := r.db.GetDB().Where("room_code = ?", roomCode).Preload("Players", activePlayers).First(&room).Error; err != nil {
+		return nil, err
+	}
+	return &room, nil
+}
+
+// 房间码是否已被使用，包含已删除的房间
+func (r *RoomRepository) CodeExists(roomCode string) (bool, error) {
+	var count int64
+	if err := r.db.GetDB().Unscoped().Model(&models.GameRoom{}).Where("room_code = ?", roomCode).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// 获取等待中的公开房间，按创建时间倒序
+func (r *RoomRepository) ListWaiting(gameMode string, limit, offset int) ([]models.GameRoom, error) {
+	var rooms []models.GameRoom
+	query := r.db.GetDB().Where("status = ? AND is_private = ?", models.RoomStatusWaiting, false)
+	if gameMode != "" {
+		query = query.Where("game_mode = ?", gameMode)
+	}
+	err := query.
+		Order("created_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&rooms).Error
+	if err != nil {
+		return nil, err
+	}
+	return rooms, nil
+}
+
+// WithRoomLock 锁定房间行后执行 fn，房间的 Players 为房间内的玩家，按加入时间排序
+// 同一房间的修改因行锁串行执行，fn 返回错误时整个事务回滚
+func (r *RoomRepository) WithRoomLock(roomID uint64, fn func(tx *RoomTx, room *models.GameRoom) error) error {
+	return r.db.GetDB().Transaction(func(tx *gorm.DB) error {
+		var room models.GameRoom
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&room, roomID).Error; err != nil {
+			return err
+		}
+		if err := activePlayers(tx).Where("room_id = ?", roomID).Find(&room.Players).Error; err != nil {
+			return err
+		}
+		return fn(&RoomTx{tx: tx}, &room)
+	})
+}
+
+// 获取玩家在房间中的记录，包含已离开的记录
+func (t *RoomTx) FindPlayer(roomID, userID uint64) (*models.RoomPlayer, error) {
+	var player models.RoomPlayer
+	if err := t.tx.Where("room_id = ? AND user_id = ?", roomID, userID).First(&player).Error; err != nil {
+		return nil, err
+	}
+	return &player, nil
+}
+
+// 新增或更新房间玩家
+func (t *RoomTx) SavePlayer(player *models.RoomPlayer) error {
+	return t.tx.Omit(clause.Associations).Save(player).Error
+}
+
+// 更新房间状态、人数和开始结束时间
+func (t *RoomTx) UpdateRoom(room *models.GameRoom) error {
+	return t.tx.Model(room).Updates(map[string]interface{}{
+		"status":          room.Status,
+		"current_players": room.CurrentPlayers,
+		"started_at":      room.StartedAt,
+		"ended_at":        room.EndedAt,
+	}).Error
+}
+
+// 房间内未离开的玩家，按加入顺序
+func activePlayers(db *gorm.DB) *gorm.DB {
+	return db.Where("left_at IS NULL").Order("joined_at, id")
+}
@@ -0,0 +1,12 @@
+-- 匹配结果预测
+-- 描述: 记录成局时评级模型给出的公平度、各玩家所在队伍的获胜概率和胜负时的预期MMR变化，
+--       用于与 game_records 的实际结果对比
+
+ALTER TABLE match_records
+    ADD COLUMN fairness DECIMAL(5,4) CHECK (fairness BETWEEN 0 AND 1); -- 预测公平度 1-|P(A)-P(B)|
+
+ALTER TABLE match_players
+    ADD COLUMN win_probability DECIMAL(5,4) CHECK (win_probability BETWEEN 0 AND 1), -- 所在队伍获胜概率
+    ADD COLUMN expected_gain DECIMAL(8,2), -- 获胜时的预期MMR变化
+    ADD COLUMN expected_loss DECIMAL(8,2); -- 失败时的预期MMR变化
+
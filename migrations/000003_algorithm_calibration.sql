@@ -0,0 +1,35 @@
+-- 匹配预测校准
+-- 描述: 按算法版本保存预测获胜概率与实际结果对比的 Brier 分数、对数损失和可靠性曲线
+
+-- 校准结果表
+CREATE TABLE algorithm_calibrations (
+    id BIGSERIAL PRIMARY KEY,
+    algorithm_version VARCHAR(20) NOT NULL, -- 匹配算法版本
+    window_start TIMESTAMP NOT NULL, -- 统计窗口 [window_start, window_end)
+    window_end TIMESTAMP NOT NULL,
+    sample_count INTEGER NOT NULL CHECK (sample_count >= 0), -- 参与统计的队伍预测数
+    brier_score DECIMAL(6,5), -- Brier 分数，越小越好
+    log_loss DECIMAL(8,5), -- 对数损失，越小越好
+    created_at TIMESTAMP DEFAULT NOW(),
+
+    CHECK (window_end > window_start)
+);
+
+-- 可靠性曲线分桶表
+CREATE TABLE calibration_bins (
+    id BIGSERIAL PRIMARY KEY,
+    calibration_id BIGINT NOT NULL REFERENCES algorithm_calibrations(id) ON DELETE CASCADE,
+    lower_bound DECIMAL(3,2) NOT NULL,
+    upper_bound DECIMAL(3,2) NOT NULL,
+    sample_count INTEGER NOT NULL CHECK (sample_count >= 0),
+    mean_predicted DECIMAL(5,4), -- 桶内平均预测概率
+    observed_rate DECIMAL(5,4), -- 桶内实际胜率
+
+    UNIQUE(calibration_id, lower_bound)
+);
+
+CREATE INDEX idx_algorithm_calibrations_version ON algorithm_calibrations(algorithm_version, created_at DESC);
+CREATE INDEX idx_calibration_bins_calibration_id ON calibration_bins(calibration_id);
+
+COMMENT ON TABLE algorithm_calibrations IS '匹配预测校准结果表';
+COMMENT ON TABLE calibration_bins IS '预测可靠性曲线分桶表';
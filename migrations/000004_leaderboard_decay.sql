@@ -0,0 +1,10 @@
+-- 排行榜分数衰减
+-- 描述: 长期未对局的高段位玩家分数向下限衰减，并增大评级不确定度
+
+ALTER TABLE leaderboards
+    ADD COLUMN rating_deviation DECIMAL(7,2) CHECK (rating_deviation > 0), -- 评级偏差（Glicko RD）
+    ADD COLUMN sigma DECIMAL(6,3) CHECK (sigma > 0), -- 技能标准差（TrueSkill）
+    ADD COLUMN last_decay_at TIMESTAMP; -- 最近一次衰减计算到的时间
+
+CREATE INDEX idx_leaderboards_tier ON leaderboards(tier, leaderboard_type);
+CREATE INDEX idx_player_game_stats_user_created ON player_game_stats(user_id, created_at DESC);
@@ -0,0 +1,7 @@
+-- 弃赛归属
+-- 描述: 标记导致对局弃赛的玩家，低优先级队列只统计玩家本人弃赛的对局，不牵连同局的其他玩家
+
+ALTER TABLE player_game_stats
+    ADD COLUMN abandoned BOOLEAN NOT NULL DEFAULT FALSE; -- 是否中途离开导致对局弃赛
+
+CREATE INDEX idx_player_game_stats_abandoned ON player_game_stats(user_id, created_at DESC) WHERE abandoned;
@@ -0,0 +1,482 @@
+package algorithm
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 模拟退火的初始温度，与单局质量差处于同一量级
+const batchInitialTemperature = 0.05
+
+// 批量匹配参数
+type BatchOptions struct {
+	Format        TeamFormat
+	TimeBudget    time.Duration           // 优化的时间预算，用完后返回当前最优解，0为不限
+	MaxIterations int                     // 优化的迭代上限，0为不限；两者都为0时只使用贪心初始解
+	Seed          int64                   // 随机种子，只受迭代上限约束时相同输入得到相同结果
+	Compatible    func(a, b *Player) bool // 不同单元的两名玩家能否同局（如搜索窗口互相接受），为空时不限制
+}
+
+// findBatchMatches 在整批排队玩家中寻找使对局质量总和最大的一组对局
+//
+// 预组队作为不可拆分的单元；对局质量为局内不同单元玩家两两匹配得分的平均值，
+// 任意一对不能同局、质量低于 min_quality、无法分队或不满足 Format.Feasible 的对局都不成立。
+// 先按优先级评分从高到低、同分按排队时间从早到晚贪心组局得到初始解，再在时间预算内用模拟退火改进：
+// 交换两局之间或对局与未匹配玩家之间同样人数的单元，或拆散若干对局后重新贪心组局。
+func findBatchMatches(ctx context.Context, alg MatchingAlgorithm, players []*Player, options BatchOptions) ([]*MatchResult, error) {
+	startTime := time.Now()
+	teamSize := options.Format.Size
+	if teamSize <= 0 {
+		return nil, fmt.Errorf("invalid team size: %d", teamSize)
+	}
+	if len(options.Format.Roles) > 0 && len(options.Format.Roles) != teamSize {
+		return nil, fmt.Errorf("team size %d does not match %d roles", teamSize, len(options.Format.Roles))
+	}
+
+	solver := newBatchSolver(ctx, alg, players, options)
+	solver.solve(startTime)
+
+	lobbies := solver.best
+	sort.SliceStable(lobbies, func(i, j int) bool {
+		return solver.queueTime(lobbies[i]).Before(solver.queueTime(lobbies[j]))
+	})
+
+	results := make([]*MatchResult, 0, len(lobbies))
+	for _, lobby := range lobbies {
+		teams := solver.teams[solver.key(lobby)]
+		var lobbyPlayers []*Player
+		for _, u := range lobby {
+			lobbyPlayers = append(lobbyPlayers, solver.units[u]...)
+		}
+		quality, _ := solver.quality(lobby)
+
+		metadata := map[string]interface{}{
+			"matcher":          "batch",
+			"candidates_count": len(players),
+			"batch_units":      len(solver.units),
+			"batch_lobbies":    len(lobbies),
+			"batch_iterations": solver.iterations,
+			"team_size":        teamSize,
+			"roles":            len(options.Format.Roles) > 0,
+			"mmr_gap":          math.Abs(teams[0].AverageMMR - teams[1].AverageMMR),
+		}
+		if teamAlg, ok := alg.(TeamRatingAlgorithm); ok {
+			if teamQuality, err := teamAlg.CalculateTeamMatchQuality(ctx, [][]*Player{teams[0].Players, teams[1].Players}); err == nil {
+				metadata["team_quality"] = teamQuality
+			}
+		}
+		metadata["calculation_time"] = time.Since(startTime)
+
+		results = append(results, &MatchResult{
+			MatchID:   NewMatchID(fmt.Sprintf("match_%d_%dv%d", lobbyPlayers[0].ID, teamSize, teamSize)),
+			Players:   lobbyPlayers,
+			Teams:     teams,
+			Quality:   quality,
+			Algorithm: alg.Name(),
+			Metadata:  metadata,
+		})
+	}
+	return results, ctx.Err()
+}
+
+// 批量匹配的求解状态，单元以下标表示
+type batchSolver struct {
+	ctx        context.Context
+	options    BatchOptions
+	lobbySize  int
+	minQuality float64
+	rng        *rand.Rand
+
+	units [][]*Player
+	sizes []int
+	score [][]float64        // 单元间玩家两两匹配得分的平均值，负数表示不能同局
+	teams map[string][]*Team // 已评估对局的分队结果，nil 表示对局不成立
+
+	lobbies    [][]int
+	pool       []int // 未进入任何对局的单元
+	total      float64
+	best       [][]int
+	bestTotal  float64
+	iterations int
+}
+
+func newBatchSolver(ctx context.Context, alg MatchingAlgorithm, players []*Player, options BatchOptions) *batchSolver {
+	s := &batchSolver{
+		ctx:        ctx,
+		options:    options,
+		lobbySize:  2 * options.Format.Size,
+		minQuality: alg.GetConfig().Thresholds["min_quality"],
+		rng:        rand.New(rand.NewSource(options.Seed)),
+		teams:      make(map[string][]*Team),
+	}
+	for _, unit := range groupUnits(players) {
+		if len(unit) > options.Format.Size || !isCompleteUnit(unit) || !rolesFeasible(unit, options.Format.Roles) {
+			continue
+		}
+		s.units = append(s.units, unit)
+		s.sizes = append(s.sizes, len(unit))
+	}
+
+	s.score = make([][]float64, len(s.units))
+	for i := range s.units {
+		s.score[i] = make([]float64, len(s.units))
+	}
+	for i := range s.units {
+		for j := i + 1; j < len(s.units); j++ {
+			score := s.unitScore(ctx, alg, s.units[i], s.units[j])
+			s.score[i][j], s.score[j][i] = score, score
+		}
+	}
+	return s
+}
+
+// 两个单元间所有玩家对的平均匹配得分，任意一对不能同局时返回 -1
+func (s *batchSolver) unitScore(ctx context.Context, alg MatchingAlgorithm, a, b []*Player) float64 {
+	var total float64
+	for _, p1 := range a {
+		for _, p2 := range b {
+			if s.options.Compatible != nil && !s.options.Compatible(p1, p2) {
+				return -1
+			}
+			score, err := alg.CalculateMatchScore(ctx, p1, p2)
+			if err != nil {
+				return -1
+			}
+			total += score
+		}
+	}
+	return total / float64(len(a)*len(b))
+}
+
+func (s *batchSolver) solve(startTime time.Time) {
+	order := make([]int, len(s.units))
+	for i := range order {
+		order[i] = i
+	}
+	// 初始解：优先级高的单元优先组局，同优先级排队最久的优先
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := s.units[order[i]][0], s.units[order[j]][0]
+		if a.PriorityScore != b.PriorityScore {
+			return a.PriorityScore > b.PriorityScore
+		}
+		return a.QueueTime.Before(b.QueueTime)
+	})
+	s.lobbies, s.pool = s.build(order, order)
+	for _, lobby := range s.lobbies {
+		q, _ := s.quality(lobby)
+		s.total += q
+	}
+	s.snapshot()
+
+	for !s.done(startTime) && len(s.lobbies) > 0 {
+		s.iterations++
+		temperature := batchInitialTemperature * (1 - s.progress(startTime))
+		if s.rng.Intn(10) < 7 {
+			s.swap(temperature)
+		} else {
+			s.rebuild(temperature)
+		}
+		if s.total > s.bestTotal+1e-9 {
+			s.snapshot()
+		}
+	}
+}
+
+func (s *batchSolver) done(startTime time.Time) bool {
+	if s.ctx.Err() != nil {
+		return true
+	}
+	if s.options.TimeBudget <= 0 && s.options.MaxIterations <= 0 {
+		return true
+	}
+	if s.options.MaxIterations > 0 && s.iterations >= s.options.MaxIterations {
+		return true
+	}
+	return s.options.TimeBudget > 0 && time.Since(startTime) >= s.options.TimeBudget
+}
+
+// 优化进度（0-1），用于降温
+func (s *batchSolver) progress(startTime time.Time) float64 {
+	progress := 0.0
+	if s.options.MaxIterations > 0 {
+		progress = float64(s.iterations) / float64(s.options.MaxIterations)
+	}
+	if s.options.TimeBudget > 0 {
+		progress = math.Max(progress, float64(time.Since(startTime))/float64(s.options.TimeBudget))
+	}
+	return math.Min(1, progress)
+}
+
+// 模拟退火接受准则：变好总是接受，变差按温度以一定概率接受
+func (s *batchSolver) accept(delta, temperature float64) bool {
+	if delta >= 0 {
+		return true
+	}
+	return temperature > 0 && s.rng.Float64() < math.Exp(delta/temperature)
+}
+
+// 交换一局中的一个单元与另一局或未匹配单元中人数相同的单元
+func (s *batchSolver) swap(temperature float64) {
+	x := s.rng.Intn(len(s.lobbies))
+	i := s.rng.Intn(len(s.lobbies[x]))
+	u := s.lobbies[x][i]
+
+	var targets [][2]int // {对局下标（-1为未匹配）, 位置}
+	if len(s.lobbies) > 1 && (len(s.pool) == 0 || s.rng.Intn(2) == 0) {
+		y := s.rng.Intn(len(s.lobbies) - 1)
+		if y >= x {
+			y++
+		}
+		for j, v := range s.lobbies[y] {
+			if s.sizes[v] == s.sizes[u] {
+				targets = append(targets, [2]int{y, j})
+			}
+		}
+	} else {
+		for j, v := range s.pool {
+			if s.sizes[v] == s.sizes[u] {
+				targets = append(targets, [2]int{-1, j})
+			}
+		}
+	}
+	if len(targets) == 0 {
+		return
+	}
+	target := targets[s.rng.Intn(len(targets))]
+	y, j := target[0], target[1]
+
+	oldX, _ := s.quality(s.lobbies[x])
+	nextX := replaceAt(s.lobbies[x], i, s.unitAt(y, j))
+	newX, ok := s.evaluate(nextX)
+	if !ok {
+		return
+	}
+	delta := newX - oldX
+	var nextY []int
+	if y >= 0 {
+		oldY, _ := s.quality(s.lobbies[y])
+		nextY = replaceAt(s.lobbies[y], j, u)
+		newY, ok := s.evaluate(nextY)
+		if !ok {
+			return
+		}
+		delta += newY - oldY
+	}
+	if !s.accept(delta, temperature) {
+		return
+	}
+
+	s.lobbies[x] = nextX
+	if y >= 0 {
+		s.lobbies[y] = nextY
+	} else {
+		s.pool[j] = u
+	}
+	s.total += delta
+}
+
+// 拆散一到两局后，以被拆散的单元为起点在未匹配单元中重新贪心组局
+func (s *batchSolver) rebuild(temperature float64) {
+	count := min(len(s.lobbies), 1+s.rng.Intn(2))
+	picked := s.rng.Perm(len(s.lobbies))[:count]
+	dissolved := make(map[int]bool, count)
+	var seeds []int
+	var removed float64
+	for _, x := range picked {
+		dissolved[x] = true
+		seeds = append(seeds, s.lobbies[x]...)
+		q, _ := s.quality(s.lobbies[x])
+		removed += q
+	}
+	s.rng.Shuffle(len(seeds), func(a, b int) { seeds[a], seeds[b] = seeds[b], seeds[a] })
+
+	pool := append(append([]int{}, s.pool...), seeds...)
+	built, rest := s.build(seeds, pool)
+	var added float64
+	for _, lobby := range built {
+		q, _ := s.quality(lobby)
+		added += q
+	}
+	if !s.accept(added-removed, temperature) {
+		return
+	}
+
+	lobbies := make([][]int, 0, len(s.lobbies)-count+len(built))
+	for x, lobby := range s.lobbies {
+		if !dissolved[x] {
+			lobbies = append(lobbies, lobby)
+		}
+	}
+	s.lobbies = append(lobbies, built...)
+	s.pool = rest
+	s.total += added - removed
+}
+
+// 依次以 seeds 中仍未使用的单元为起点，从 pool 中按与起点的得分从高到低贪心填满对局
+// 返回组成的对局和 pool 中剩余的单元
+func (s *batchSolver) build(seeds, pool []int) ([][]int, []int) {
+	used := make(map[int]bool)
+	var lobbies [][]int
+	for _, seed := range seeds {
+		if used[seed] {
+			continue
+		}
+		var candidates []int
+		for _, v := range pool {
+			if v != seed && !used[v] && s.score[seed][v] >= s.minQuality {
+				candidates = append(candidates, v)
+			}
+		}
+		sort.SliceStable(candidates, func(a, b int) bool {
+			return s.score[seed][candidates[a]] > s.score[seed][candidates[b]]
+		})
+
+		lobby, size := []int{seed}, s.sizes[seed]
+		for _, v := range candidates {
+			if size+s.sizes[v] > s.lobbySize || !s.compatible(lobby, v) {
+				continue
+			}
+			next := append(append([]int{}, lobby...), v)
+			if !rolesFeasible(s.players(next), s.options.Format.Roles) {
+				continue
+			}
+			if size+s.sizes[v] == s.lobbySize {
+				if _, ok := s.evaluate(next); !ok {
+					continue
+				}
+			}
+			lobby, size = next, size+s.sizes[v]
+			if size == s.lobbySize {
+				break
+			}
+		}
+		if size < s.lobbySize {
+			continue
+		}
+		for _, u := range lobby {
+			used[u] = true
+		}
+		lobbies = append(lobbies, lobby)
+	}
+
+	var rest []int
+	for _, v := range pool {
+		if !used[v] {
+			rest = append(rest, v)
+		}
+	}
+	return lobbies, rest
+}
+
+// 单元能否与局内已有单元同局
+func (s *batchSolver) compatible(lobby []int, v int) bool {
+	for _, u := range lobby {
+		if s.score[u][v] < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// 对局质量：不同单元玩家两两得分的加权平均；任意一对不能同局时不成立
+func (s *batchSolver) quality(lobby []int) (float64, bool) {
+	var total, weight float64
+	for a := 0; a < len(lobby); a++ {
+		for b := a + 1; b < len(lobby); b++ {
+			score := s.score[lobby[a]][lobby[b]]
+			if score < 0 {
+				return 0, false
+			}
+			pairs := float64(s.sizes[lobby[a]] * s.sizes[lobby[b]])
+			total += score * pairs
+			weight += pairs
+		}
+	}
+	if weight == 0 {
+		return 0, false
+	}
+	return total / weight, true
+}
+
+// 评估满员对局：质量达到阈值、能按格式分队且满足额外约束，分队结果缓存复用
+func (s *batchSolver) evaluate(lobby []int) (float64, bool) {
+	quality, ok := s.quality(lobby)
+	if !ok || quality < s.minQuality {
+		return 0, false
+	}
+	sorted := sortedUnits(lobby)
+	key := s.key(sorted)
+	teams, evaluated := s.teams[key]
+	if !evaluated {
+		players := s.players(sorted)
+		teams, _ = BalanceRoleTeams(players, s.options.Format)
+		if teams != nil && s.options.Format.Feasible != nil && !s.options.Format.Feasible(players) {
+			teams = nil
+		}
+		s.teams[key] = teams
+	}
+	return quality, teams != nil
+}
+
+func (s *batchSolver) snapshot() {
+	s.best = make([][]int, len(s.lobbies))
+	for i, lobby := range s.lobbies {
+		s.best[i] = append([]int{}, lobby...)
+	}
+	s.bestTotal = s.total
+}
+
+func (s *batchSolver) unitAt(lobby, position int) int {
+	if lobby < 0 {
+		return s.pool[position]
+	}
+	return s.lobbies[lobby][position]
+}
+
+func (s *batchSolver) players(lobby []int) []*Player {
+	var players []*Player
+	for _, u := range lobby {
+		players = append(players, s.units[u]...)
+	}
+	return players
+}
+
+// 对局中最早的排队时间
+func (s *batchSolver) queueTime(lobby []int) time.Time {
+	earliest := s.units[lobby[0]][0].QueueTime
+	for _, u := range lobby[1:] {
+		if t := s.units[u][0].QueueTime; t.Before(earliest) {
+			earliest = t
+		}
+	}
+	return earliest
+}
+
+// 对局的缓存键，与单元顺序无关
+func (s *batchSolver) key(lobby []int) string {
+	sorted := sortedUnits(lobby)
+	parts := make([]string, len(sorted))
+	for i, u := range sorted {
+		parts[i] = strconv.Itoa(u)
+	}
+	return strings.Join(parts, ",")
+}
+
+// 分队结果与单元的排列有关，统一按下标排序后计算
+func sortedUnits(lobby []int) []int {
+	sorted := append([]int{}, lobby...)
+	sort.Ints(sorted)
+	return sorted
+}
+
+func replaceAt(lobby []int, i, unit int) []int {
+	next := append([]int{}, lobby...)
+	next[i] = unit
+	return next
+}
@@ -0,0 +1,85 @@
+package algorithm
+
+import "math"
+
+// 对数损失计算时概率的截断，避免 log(0)
+const calibrationEpsilon = 1e-15
+
+// 一条预测样本：成局时预测的获胜概率与实际胜负
+type CalibrationSample struct {
+	Predicted float64 `json:"predicted"`
+	Won       bool    `json:"won"`
+}
+
+// 可靠性曲线的一个分桶：预测概率落在 [Lower, Upper) 的样本
+type ReliabilityBin struct {
+	Lower         float64 `json:"lower"`
+	Upper         float64 `json:"upper"`
+	Count         int     `json:"count"`
+	MeanPredicted float64 `json:"mean_predicted"` //桶内平均预测概率
+	ObservedRate  float64 `json:"observed_rate"`  //桶内实际胜率
+}
+
+// 预测校准结果，Brier分数和对数损失越小越好
+type CalibrationReport struct {
+	Samples     int              `json:"samples"`
+	BrierScore  float64          `json:"brier_score"`
+	LogLoss     float64          `json:"log_loss"`
+	Reliability []ReliabilityBin `json:"reliability"`
+}
+
+// Calibrate 计算一组预测的 Brier 分数、对数损失和等宽分桶的可靠性曲线
+// 校准良好时每个桶的实际胜率接近平均预测概率
+func Calibrate(samples []CalibrationSample, bins int) *CalibrationReport {
+	if bins <= 0 {
+		bins = 10
+	}
+	report := &CalibrationReport{
+		Samples:     len(samples),
+		Reliability: make([]ReliabilityBin, bins),
+	}
+	for i := range report.Reliability {
+		report.Reliability[i].Lower = float64(i) / float64(bins)
+		report.Reliability[i].Upper = float64(i+1) / float64(bins)
+	}
+	if len(samples) == 0 {
+		return report
+	}
+
+	var brier, logLoss float64
+	wins := make([]int, bins)
+	for _, sample := range samples {
+		p := math.Max(0, math.Min(1, sample.Predicted))
+		outcome := 0.0
+		if sample.Won {
+			outcome = 1.0
+		}
+		brier += (p - outcome) * (p - outcome)
+
+		clipped := math.Max(calibrationEpsilon, math.Min(1-calibrationEpsilon, p))
+		if sample.Won {
+			logLoss -= math.Log(clipped)
+		} else {
+			logLoss -= math.Log(1 - clipped)
+		}
+
+		bin := min(bins-1, int(p*float64(bins)))
+		report.Reliability[bin].Count++
+		report.Reliability[bin].MeanPredicted += p
+		if sample.Won {
+			wins[bin]++
+		}
+	}
+
+	n := float64(len(samples))
+	report.BrierScore = brier / n
+	report.LogLoss = logLoss / n
+	for i := range report.Reliability {
+		bin := &report.Reliability[i]
+		if bin.Count > 0 {
+			bin.MeanPredicted /= float64(bin.Count)
+			bin.ObservedRate = float64(wins[i]) / float64(bin.Count)
+		}
+	}
+	return report
+}
@@ -0,0 +1,130 @@
+package algorithm
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCalibrate(t *testing.T) {
+	// 与实现相同地截断到 1-epsilon，避免常量运算精度不同
+	clipped := 1 - calibrationEpsilon
+	tests := []struct {
+		name        string
+		samples     []CalibrationSample
+		bins        int
+		wantBins    int
+		wantBrier   float64
+		wantLogLoss float64
+		// 分桶下标 -> 样本数
+		wantCounts map[int]int
+	}{
+		{
+			name:     "没有样本",
+			bins:     5,
+			wantBins: 5,
+		},
+		{
+			name:     "分桶数不大于0时使用10个",
+			bins:     0,
+			wantBins: 10,
+		},
+		{
+			name: "完全正确的预测",
+			samples: []CalibrationSample{
+				{Predicted: 1, Won: true},
+				{Predicted: 0, Won: false},
+			},
+			bins:        10,
+			wantBins:    10,
+			wantBrier:   0,
+			wantLogLoss: -math.Log(clipped),
+			wantCounts:  map[int]int{0: 1, 9: 1},
+		},
+		{
+			name: "五五开的预测",
+			samples: []CalibrationSample{
+				{Predicted: 0.5, Won: true},
+				{Predicted: 0.5, Won: false},
+			},
+			bins:        4,
+			wantBins:    4,
+			wantBrier:   0.25,
+			wantLogLoss: math.Log(2),
+			wantCounts:  map[int]int{2: 2},
+		},
+		{
+			name: "超出范围的概率截断到0和1",
+			samples: []CalibrationSample{
+				{Predicted: 1.2, Won: false},
+				{Predicted: -0.3, Won: false},
+			},
+			bins:        10,
+			wantBins:    10,
+			wantBrier:   0.5,
+			wantLogLoss: (-math.Log(1-clipped) - math.Log(clipped)) / 2,
+			wantCounts:  map[int]int{0: 1, 9: 1},
+		},
+		{
+			name: "按预测概率分桶",
+			samples: []CalibrationSample{
+				{Predicted: 0.1, Won: false},
+				{Predicted: 0.3, Won: true},
+				{Predicted: 0.7, Won: true},
+				{Predicted: 0.9, Won: true},
+			},
+			bins:        2,
+			wantBins:    2,
+			wantBrier:   (0.01 + 0.49 + 0.09 + 0.01) / 4,
+			wantLogLoss: -(math.Log(0.9) + math.Log(0.3) + math.Log(0.7) + math.Log(0.9)) / 4,
+			wantCounts:  map[int]int{0: 2, 1: 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := Calibrate(tt.samples, tt.bins)
+			if report.Samples != len(tt.samples) {
+				t.Errorf("Samples = %d, want %d", report.Samples, len(tt.samples))
+			}
+			if len(report.Reliability) != tt.wantBins {
+				t.Fatalf("len(Reliability) = %d, want %d", len(report.Reliability), tt.wantBins)
+			}
+			if math.Abs(report.BrierScore-tt.wantBrier) > 1e-9 {
+				t.Errorf("BrierScore = %v, want %v", report.BrierScore, tt.wantBrier)
+			}
+			if math.Abs(report.LogLoss-tt.wantLogLoss) > 1e-9 {
+				t.Errorf("LogLoss = %v, want %v", report.LogLoss, tt.wantLogLoss)
+			}
+			for i, bin := range report.Reliability {
+				if lower := float64(i) / float64(tt.wantBins); math.Abs(bin.Lower-lower) > 1e-12 {
+					t.Errorf("bin %d Lower = %v, want %v", i, bin.Lower, lower)
+				}
+				if upper := float64(i+1) / float64(tt.wantBins); math.Abs(bin.Upper-upper) > 1e-12 {
+					t.Errorf("bin %d Upper = %v, want %v", i, bin.Upper, upper)
+				}
+				if bin.Count != tt.wantCounts[i] {
+					t.Errorf("bin %d Count = %d, want %d", i, bin.Count, tt.wantCounts[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCalibrateBinRates(t *testing.T) {
+	samples := []CalibrationSample{
+		{Predicted: 0.62, Won: true},
+		{Predicted: 0.7, Won: true},
+		{Predicted: 0.65, Won: false},
+		{Predicted: 0.62, Won: true},
+	}
+	bin := Calibrate(samples, 5).Reliability[3]
+	if bin.Count != 4 {
+		t.Fatalf("bin Count = %d, want 4", bin.Count)
+	}
+	if math.Abs(bin.MeanPredicted-0.6475) > 1e-9 {
+		t.Errorf("MeanPredicted = %v, want 0.6475", bin.MeanPredicted)
+	}
+	if math.Abs(bin.ObservedRate-0.75) > 1e-9 {
+		t.Errorf("ObservedRate = %v, want 0.75", bin.ObservedRate)
+	}
+}
@@ -0,0 +1,79 @@
+package algorithm
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+var ErrNoDataCenter = errors.New("no data center within ping limit")
+
+// 缺少机房测速时视为不可达
+const unreachablePing = math.MaxInt32
+
+// PingTo 玩家到指定机房的延迟
+// 未上报分机房延迟的玩家统一使用 Ping
+func (p *Player) PingTo(dataCenter string) int {
+	if len(p.Pings) == 0 {
+		return p.Ping
+	}
+	if ping, ok := p.Pings[dataCenter]; ok {
+		return ping
+	}
+	return unreachablePing
+}
+
+// PairPing 两名玩家在最佳共同机房下的较大延迟
+// 任一方未上报分机房延迟时退回两人平均延迟
+func PairPing(p1, p2 *Player) int {
+	if len(p1.Pings) == 0 || len(p2.Pings) == 0 {
+		return (p1.Ping + p2.Ping) / 2
+	}
+	best := unreachablePing
+	for dc, ping := range p1.Pings {
+		if other, ok := p2.Pings[dc]; ok {
+			best = min(best, max(ping, other))
+		}
+	}
+	return best
+}
+
+// SelectDataCenter 在候选机房中选出使对局最大延迟最小的机房，最大延迟相同时取总延迟更小者
+// 没有候选机房时使用玩家测速过的所有机房，仍然没有时不选择机房；
+// maxPing 大于0时最大延迟不能超过该值
+func SelectDataCenter(players []*Player, dataCenters []string, maxPing int) (string, int, error) {
+	if len(dataCenters) == 0 {
+		seen := make(map[string]bool)
+		for _, p := range players {
+			for dc := range p.Pings {
+				if !seen[dc] {
+					seen[dc] = true
+					dataCenters = append(dataCenters, dc)
+				}
+			}
+		}
+		if len(dataCenters) == 0 {
+			return "", 0, nil
+		}
+	}
+
+	bestDC, bestWorst, bestTotal := "", unreachablePing, unreachablePing
+	for _, dc := range dataCenters {
+		worst, total := 0, 0
+		for _, p := range players {
+			ping := p.PingTo(dc)
+			worst = max(worst, ping)
+			total = min(total+ping, unreachablePing)
+		}
+		if worst < bestWorst || (worst == bestWorst && total < bestTotal) {
+			bestDC, bestWorst, bestTotal = dc, worst, total
+		}
+	}
+	if bestDC == "" || bestWorst == unreachablePing {
+		return "", 0, fmt.Errorf("%w: none of %d data centers reachable by all players", ErrNoDataCenter, len(dataCenters))
+	}
+	if maxPing > 0 && bestWorst > maxPing {
+		return "", 0, fmt.Errorf("%w: best %s at %dms exceeds %dms", ErrNoDataCenter, bestDC, bestWorst, maxPing)
+	}
+	return bestDC, bestWorst, nil
+}
@@ -19,10 +19,7 @@ type ELOAlgorithm struct {
 func NewELOAlgorithm(config *config.AlgorithmConfig) *ELOAlgorithm {
 	return &ELOAlgorithm{
 		config: config,
-		stats: &AlgorithmStats{
-			QualityDistribution: make(map[string]int64),
-			LastUpdated:         time.Now(),
-		},
+		stats:  newAlgorithmStats(),
 	}
 }
 
@@ -63,19 +60,16 @@ func (e *ELOAlgorithm) CalculateMatchScore(ctx context.Context, p1, p2 *Player)
 	}
 
 	// 基础因子计算
-	levelScore := e.calculateLevelScore(p1, p2)
-	winRateScore := e.calculateWinRateScore(p1, p2)
-	pingScore := e.calculatePingScore(p1, p2)
 	mmrScore := e.calculateMMRScore(p1, p2)
 
 	// 加权计算总分
-	totalScore := e.config.Weights["level"]*levelScore + e.config.Weights["winrate"]*winRateScore + e.config.Weights["ping"]*pingScore + e.config.Weights["mmr"]*mmrScore
+	totalScore := weightedScore(e.config, levelScore(e.config, p1, p2), winRateScore(e.config, p1, p2), pingScore(e.config, p1, p2), mmrScore)
 
-	// 基于排队时间动态调整
-	if e.config.EnableDynamicAdjustment {
-		queueTimeBonus := e.calculateQueueTimeBonus(p1, p2)
-		totalScore *= queueTimeBonus
+	// 定级期玩家尽量不与老玩家匹配，评级尚未收敛时双方体验都差
+	if (e.IsProvisional(p1) && e.isVeteran(p2)) || (e.IsProvisional(p2) && e.isVeteran(p1)) {
+		totalScore *= 1 - floatParam(e.config, "provisional_veteran_penalty", 0.3)
 	}
+
 	// 返回最终得分，确定分数在0-1之间
 	return math.Max(0, math.Min(1, totalScore)), nil
 
@@ -87,32 +81,8 @@ func (e *ELOAlgorithm) FindOptimalMatch(ctx context.Context, player *Player, can
 		return nil, fmt.Errorf("no candidates found")
 	}
 
-	type candidateScore struct {
-		player *Player
-		score  float64
-	}
-
-	var scores []candidateScore
-
 	// 计算所有候选者的匹配分数
-	for _, candidate := range candidates {
-
-		if candidate.ID == player.ID {
-			// 跳过自己
-			continue
-		}
-
-		score, err := e.CalculateMatchScore(ctx, player, candidate)
-		if err != nil {
-			continue //跳过计算错误
-
-		}
-		// 只考虑超过最低质量阈值的匹配
-
-		if score >= e.config.Thresholds["min_quality"] {
-			scores = append(scores, candidateScore{player: candidate, score: score})
-		}
-	}
+	scores := qualifiedCandidates(ctx, e, player, candidates, e.config.Thresholds["min_quality"])
 
 	if len(scores) == 0 {
 		return nil, fmt.Errorf("no suitable matches found")
@@ -130,7 +100,7 @@ func (e *ELOAlgorithm) FindOptimalMatch(ctx context.Context, player *Player, can
 	e.updateStats(bestMatch.score)
 
 	return &MatchResult{
-		MatchID:    fmt.Sprintf("match_%d_%d_%d", player.ID, bestMatch.player.ID, time.Now().Unix()),
+		MatchID:    NewMatchID(fmt.Sprintf("match_%d_%d", player.ID, bestMatch.player.ID)),
 		Players:    []*Player{player, bestMatch.player},
 		Quality:    bestMatch.score,
 		Confidence: e.calculateConfidence(player, bestMatch.player),
@@ -144,12 +114,42 @@ func (e *ELOAlgorithm) FindOptimalMatch(ctx context.Context, player *Player, can
 
 }
 
-// CalculateMMR 计算新的MMR评级
+// FindTeamMatch 按对局格式为玩家组建两队对局
+func (e *ELOAlgorithm) FindTeamMatch(ctx context.Context, player *Player, candidates []*Player, format TeamFormat) (*MatchResult, error) {
+	result, err := findTeamMatch(ctx, e, player, candidates, format)
+	if err != nil {
+		return nil, err
+	}
+	result.Confidence = 1.0
+	for _, p := range result.Players[1:] {
+		result.Confidence = math.Min(result.Confidence, e.calculateConfidence(player, p))
+	}
+	e.updateStats(result.Quality)
+	return result, nil
+}
+
+// FindBatchMatches 在整批排队玩家中全局优化组局，见 findBatchMatches
+func (e *ELOAlgorithm) FindBatchMatches(ctx context.Context, players []*Player, options BatchOptions) ([]*MatchResult, error) {
+	results, err := findBatchMatches(ctx, e, players, options)
+	if err != nil {
+		return nil, err
+	}
+	for _, result := range results {
+		result.Confidence = 1.0
+		for _, p := range result.Players[1:] {
+			result.Confidence = math.Min(result.Confidence, e.calculateConfidence(result.Players[0], p))
+		}
+		e.updateStats(result.Quality)
+	}
+	return results, nil
+}
+
+// CalculateMMR 计算新的MMR评级，K值见 kFactorFor
 func (e *ELOAlgorithm) CalculateMMR(ctx context.Context, player *Player, gameResult *GameResult) (float64, error) {
-	kFactor := e.config.Parameters["k_factor"].(float64)
+	kFactor := e.kFactorFor(player, gameResult.IsWin)
 
 	// 计算期望胜率
-	expectedScore := 1.0 / (1.0 + math.Pow(10, (gameResult.OpponentMMR-player.MMR)/400))
+	expectedScore := eloExpected(player.MMR, gameResult.OpponentMMR)
 
 	// 实际得分
 	actualScore := 0.0
@@ -164,58 +164,37 @@ func (e *ELOAlgorithm) CalculateMMR(ctx context.Context, player *Player, gameRes
 	newMMR := player.MMR + kFactor*(actualScore-expectedScore) + performanceAdjustment*kFactor
 
 	// 应用边界限制
-	floor := e.config.Parameters["rating_floor"].(float64)
-	ceiling := e.config.Parameters["rating_ceiling"].(float64)
+	floor := floatParam(e.config, "rating_floor", 0)
+	ceiling := floatParam(e.config, "rating_ceiling", math.MaxFloat64)
 	newMMR = math.Max(floor, math.Min(ceiling, newMMR))
 
 	return newMMR, nil
 }
 
-func (e *ELOAlgorithm) ValidatePlayer(player *Player) error {
-	if player == nil {
-		return fmt.Errorf("player cannot be nil")
-	}
-	if player.ID <= 0 {
-		return fmt.Errorf("invalid player ID: %d", player.ID)
-	}
-	if player.Level < 1 || player.Level > 100 {
-		return fmt.Errorf("invalid player level: %d", player.Level)
-	}
-	if player.WinRate < 0 || player.WinRate > 1 {
-		return fmt.Errorf("invalid win rate: %f", player.WinRate)
-	}
-	if player.Ping < 0 {
-		return fmt.Errorf("invalid ping: %d", player.Ping)
+// PredictOutcome 以两队平均MMR的ELO期望胜率作为获胜概率，
+// MMR变化与 CalculateMMR 一致，以对方队伍平均MMR作为对手评级
+func (e *ELOAlgorithm) PredictOutcome(ctx context.Context, teams []*Team) (*MatchPrediction, error) {
+	if err := validatePredictionTeams(teams); err != nil {
+		return nil, err
 	}
 
-	return nil
-}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
 
-func (e *ELOAlgorithm) calculateLevelScore(p1, p2 *Player) float64 {
-	levelDiff := math.Abs(float64(p1.Level - p2.Level))
-	maxDiff := float64(e.config.MaxLevelDiff)
-	if levelDiff > maxDiff {
-		return 0
-	}
-	return 1.0 - (levelDiff / maxDiff)
-}
+	floor := floatParam(e.config, "rating_floor", 0)
+	ceiling := floatParam(e.config, "rating_ceiling", math.MaxFloat64)
 
-func (e *ELOAlgorithm) calculateWinRateScore(p1, p2 *Player) float64 {
-	winRateDiff := math.Abs(p1.WinRate - p2.WinRate)
-	maxDiff := e.config.MaxWinRateDiff
-	if winRateDiff > maxDiff {
-		return 0
-	}
-	return 1.0 - (winRateDiff / maxDiff)
+	winA := eloExpected(averageMMR(teams[0].Players), averageMMR(teams[1].Players))
+	return newMatchPrediction(e.config.Name, teams, winA, func(player *Player, opponents []*Player) (float64, float64) {
+		expected := eloExpected(player.MMR, averageMMR(opponents))
+		gain := math.Min(ceiling, player.MMR+e.kFactorFor(player, true)*(1-expected)) - player.MMR
+		loss := math.Max(floor, player.MMR-e.kFactorFor(player, false)*expected) - player.MMR
+		return gain, loss
+	}), nil
 }
 
-func (e *ELOAlgorithm) calculatePingScore(p1, p2 *Player) float64 {
-	avgPing := float64(p1.Ping+p2.Ping) / 2
-	maxPing := float64(e.config.MaxPingDiff)
-	if avgPing > maxPing {
-		return 0
-	}
-	return 1.0 - (avgPing / maxPing)
+func (e *ELOAlgorithm) ValidatePlayer(player *Player) error {
+	return validatePlayer(player)
 }
 
 func (e *ELOAlgorithm) calculateMMRScore(p1, p2 *Player) float64 {
@@ -224,25 +203,79 @@ func (e *ELOAlgorithm) calculateMMRScore(p1, p2 *Player) float64 {
 	return math.Exp(-mmrDiff * mmrDiff / (2 * 200 * 200))
 }
 
-func (e *ELOAlgorithm) calculateQueueTimeBonus(p1, p2 *Player) float64 {
-	avgQueueTime := (time.Since(p1.QueueTime) + time.Since(p2.QueueTime)) / 2
-	maxQueueTime := time.Duration(e.config.MaxQueueTime) * time.Second
-	if avgQueueTime <= maxQueueTime {
-		return 1.0
+// 匹配置信度取双方中评级置信度较低的一方
+func (e *ELOAlgorithm) calculateConfidence(p1, p2 *Player) float64 {
+	return math.Min(e.playerConfidence(p1), e.playerConfidence(p2))
+}
+
+// 玩家评级的置信度：随场次增长，达到 veteran_games 时为1；
+// 定级期内不超过0.5，疑似小号的玩家评级明显偏低，置信度减半
+func (e *ELOAlgorithm) playerConfidence(p *Player) float64 {
+	games := float64(p.WinCount + p.LoseCount)
+	confidence := math.Min(1.0, games/floatParam(e.config, "veteran_games", 50))
+	if e.IsProvisional(p) {
+		confidence = 0.5 * games / floatParam(e.config, "placement_games", 10)
+	}
+	if e.isSmurfSuspect(p) {
+		confidence /= 2
 	}
-	// 超时后逐渐放宽标准
-	bonus := 1.0 + (avgQueueTime.Seconds()-maxQueueTime.Seconds())/maxQueueTime.Seconds()*e.config.QueueTimeMultiplier
-	return math.Min(3.0, bonus) // 最多放宽3倍
+	return confidence
 }
 
-func (e *ELOAlgorithm) calculateConfidence(p1, p2 *Player) float64 {
-	// 基于游戏数量和近期表现计算置信度
-	p1Games := float64(p1.WinCount + p1.LoseCount)
-	p2Games := float64(p2.WinCount + p2.LoseCount)
+// IsProvisional 玩家是否处于定级期：总场次少于 placement_games
+func (e *ELOAlgorithm) IsProvisional(p *Player) bool {
+	return p.WinCount+p.LoseCount < PlacementGames(e.config)
+}
 
-	minGames := math.Min(p1Games, p2Games)
-	confidence := math.Min(1.0, minGames/50.0)
-	return confidence
+// PlacementGames 定级期的场次，达到该场次的玩家不再处于定级期
+func PlacementGames(cfg *config.AlgorithmConfig) int {
+	return int(math.Ceil(floatParam(cfg, "placement_games", 10)))
+}
+
+// 总场次达到 veteran_games 的老玩家
+func (e *ELOAlgorithm) isVeteran(p *Player) bool {
+	return float64(p.WinCount+p.LoseCount) >= floatParam(e.config, "veteran_games", 50)
+}
+
+// 本局的K值：定级期使用 placement_k_factor；
+// 疑似小号的玩家获胜时再乘以 smurf_k_multiplier，加速升到真实水平
+func (e *ELOAlgorithm) kFactorFor(p *Player, isWin bool) float64 {
+	kFactor := floatParam(e.config, "k_factor", 32)
+	if e.IsProvisional(p) {
+		kFactor = floatParam(e.config, "placement_k_factor", 2*kFactor)
+	}
+	if isWin && e.isSmurfSuspect(p) {
+		kFactor *= floatParam(e.config, "smurf_k_multiplier", 2)
+	}
+	return kFactor
+}
+
+// 近期战绩超出评级预期的程度：近期对局实际得分与ELO期望得分之差的平均值，
+// 近期对局少于 smurf_min_games 时为0
+func (e *ELOAlgorithm) overperformance(p *Player) float64 {
+	minGames := max(1, int(floatParam(e.config, "smurf_min_games", 5)))
+	if len(p.RecentGames) < minGames {
+		return 0
+	}
+	var surplus float64
+	for _, game := range p.RecentGames {
+		actual := 0.0
+		if game.IsWin {
+			actual = 1.0
+		}
+		surplus += actual - eloExpected(p.MMR, game.OpponentMMR)
+	}
+	return surplus / float64(len(p.RecentGames))
+}
+
+// 近期战绩远超评级，疑似小号
+func (e *ELOAlgorithm) isSmurfSuspect(p *Player) bool {
+	return e.overperformance(p) >= floatParam(e.config, "smurf_threshold", 0.3)
+}
+
+// ELO期望胜率
+func eloExpected(rating, opponent float64) float64 {
+	return 1.0 / (1.0 + math.Pow(10, (opponent-rating)/400))
 }
 
 func (e *ELOAlgorithm) updateStats(quality float64) {
@@ -250,30 +283,19 @@ func (e *ELOAlgorithm) updateStats(quality float64) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
-	e.stats.TotalMatches++
-	e.stats.SuccessfulMatches++
-
-	//更新平均质量
-	e.stats.AverageMatchQuality = (e.stats.AverageMatchQuality*float64(e.stats.TotalMatches-1) + quality) / float64(e.stats.TotalMatches)
-	// 更新质量分布
-	qualityBucket := fmt.Sprintf("%.1f-%.1f", math.Floor(quality*10)/10, math.Ceil(quality*10)/10)
-	e.stats.QualityDistribution[qualityBucket]++
-	e.stats.LastUpdated = time.Now()
+	recordMatchQuality(e.stats, quality)
 }
 
 func (e *ELOAlgorithm) GetStats() *AlgorithmStats {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
 
-	return e.stats
+	return e.stats.snapshot()
 }
 
 func (e *ELOAlgorithm) ResetStats() {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
-	e.stats = &AlgorithmStats{
-		QualityDistribution: make(map[string]int64),
-		LastUpdated:         time.Now(),
-	}
+	e.stats = newAlgorithmStats()
 }
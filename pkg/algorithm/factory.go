@@ -1,12 +1,25 @@
 package algorithm
 
 import (
+	"errors"
 	"fmt"
 	"sync"
+
+	"github.com/mangooer/gamehub-arena/internal/config"
+)
+
+var (
+	ErrAlgorithmNotFound     = errors.New("algorithm not found")
+	ErrAlgorithmDisabled     = errors.New("algorithm disabled")
+	ErrAlgorithmNotAvailable = errors.New("algorithm has no implementation")
 )
 
+// 算法构造函数，使用 match.algorithms 下对应的配置创建实例
+type AlgorithmConstructor func(config *config.AlgorithmConfig) MatchingAlgorithm
+
 type AlgorithmFactory struct {
-	algorithms map[string]func() MatchingAlgorithm
+	algorithms map[string]AlgorithmConstructor
+	configs    map[string]config.AlgorithmConfig
 	instances  map[string]MatchingAlgorithm
 	mu         sync.RWMutex
 }
@@ -19,19 +32,56 @@ var (
 func InitFactory() *AlgorithmFactory {
 	once.Do(func() {
 		defaultFactory = &AlgorithmFactory{
-			algorithms: make(map[string]func() MatchingAlgorithm),
+			algorithms: make(map[string]AlgorithmConstructor),
+			configs:    make(map[string]config.AlgorithmConfig),
 			instances:  make(map[string]MatchingAlgorithm),
 		}
+		// 注册内置算法，名称与配置文件 match.algorithms 下的键一致
+		defaultFactory.RegisterAlgorithm("elo", func(cfg *config.AlgorithmConfig) MatchingAlgorithm {
+			return NewELOAlgorithm(cfg)
+		})
+		defaultFactory.RegisterAlgorithm("glicko", func(cfg *config.AlgorithmConfig) MatchingAlgorithm {
+			return NewGlickoAlgorithm(cfg)
+		})
+		defaultFactory.RegisterAlgorithm("trueskill", func(cfg *config.AlgorithmConfig) MatchingAlgorithm {
+			return NewTrueSkillAlgorithm(cfg)
+		})
 	})
 	return defaultFactory
 }
 
-func (f *AlgorithmFactory) RegisterAlgorithm(name string, algorithm func() MatchingAlgorithm) {
+func (f *AlgorithmFactory) RegisterAlgorithm(name string, algorithm AlgorithmConstructor) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 	f.algorithms[name] = algorithm
 }
 
+// Configure 加载算法配置，已创建的实例会被丢弃并在下次获取时按新配置重建
+func (f *AlgorithmFactory) Configure(cfg *config.MatchConfig) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.configs = make(map[string]config.AlgorithmConfig, len(cfg.Algorithms))
+	for name, algorithmConfig := range cfg.Algorithms {
+		f.configs[name] = algorithmConfig
+	}
+	f.instances = make(map[string]MatchingAlgorithm)
+}
+
+// AvailableAlgorithms 返回已配置、已启用且有实现的算法名称
+func (f *AlgorithmFactory) AvailableAlgorithms() []string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	var names []string
+	for name, cfg := range f.configs {
+		if _, exists := f.algorithms[name]; exists && cfg.Enabled {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
 func (f *AlgorithmFactory) GetAlgorithm(name string) (MatchingAlgorithm, error) {
 	// 🔍 快速路径：使用读锁检查已存在的实例
 	f.mu.RLock()
@@ -51,13 +101,21 @@ func (f *AlgorithmFactory) GetAlgorithm(name string) (MatchingAlgorithm, error)
 		return instance, nil
 	}
 
+	cfg, exists := f.configs[name]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrAlgorithmNotFound, name)
+	}
+	if !cfg.Enabled {
+		return nil, fmt.Errorf("%w: %s", ErrAlgorithmDisabled, name)
+	}
+
 	// 🏭 创建新实例
 	fn, exists := f.algorithms[name]
 	if !exists {
-		return nil, fmt.Errorf("algorithm %s not found", name)
+		return nil, fmt.Errorf("%w: %s", ErrAlgorithmNotAvailable, name)
 	}
 
-	instance = fn()
+	instance = fn(&cfg)
 	f.instances[name] = instance
 	return instance, nil
 }
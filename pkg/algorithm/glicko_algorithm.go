@@ -0,0 +1,375 @@
+package algorithm
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/mangooer/gamehub-arena/internal/config"
+)
+
+const (
+	glickoScale   = 173.7178 // Glicko 与 Glicko-2 量纲之间的换算系数
+	glickoBase    = 1500.0   // Glicko-2 量纲的零点
+	glickoEpsilon = 0.000001 // 波动率迭代收敛精度
+)
+
+// GlickoAlgorithm Glicko-2 评级算法
+// 与ELO相比，每个玩家额外维护评级偏差(RD)、波动率和当前评级周期的开始时间，
+// 三者都保存在玩家上，评级不依赖进程内状态
+type GlickoAlgorithm struct {
+	config *config.AlgorithmConfig
+	stats  *AlgorithmStats
+	mu     sync.RWMutex
+}
+
+func NewGlickoAlgorithm(config *config.AlgorithmConfig) *GlickoAlgorithm {
+	return &GlickoAlgorithm{
+		config: config,
+		stats:  newAlgorithmStats(),
+	}
+}
+
+func (g *GlickoAlgorithm) Name() string {
+	return g.config.Name
+}
+
+func (g *GlickoAlgorithm) Version() string {
+	return g.config.Version
+}
+
+func (g *GlickoAlgorithm) Description() string {
+	return g.config.Description
+}
+
+func (g *GlickoAlgorithm) SetConfig(config *config.AlgorithmConfig) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.config = config
+	return nil
+}
+
+func (g *GlickoAlgorithm) GetConfig() *config.AlgorithmConfig {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.config
+}
+
+func (g *GlickoAlgorithm) CalculateMatchScore(ctx context.Context, p1, p2 *Player) (float64, error) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	if err := g.ValidatePlayer(p1); err != nil {
+		return 0, fmt.Errorf("player 1 validation failed: %w", err)
+	}
+	if err := g.ValidatePlayer(p2); err != nil {
+		return 0, fmt.Errorf("player 2 validation failed: %w", err)
+	}
+
+	totalScore := weightedScore(g.config, levelScore(g.config, p1, p2), winRateScore(g.config, p1, p2), pingScore(g.config, p1, p2), g.calculateMMRScore(p1, p2))
+
+	return math.Max(0, math.Min(1, totalScore)), nil
+}
+
+func (g *GlickoAlgorithm) FindOptimalMatch(ctx context.Context, player *Player, candidates []*Player) (*MatchResult, error) {
+	startTime := time.Now()
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no candidates found")
+	}
+
+	scores := qualifiedCandidates(ctx, g, player, candidates, g.GetConfig().Thresholds["min_quality"])
+	if len(scores) == 0 {
+		return nil, fmt.Errorf("no suitable matches found")
+	}
+
+	bestMatch := scores[0]
+	for _, candidate := range scores[1:] {
+		if candidate.score > bestMatch.score {
+			bestMatch = candidate
+		}
+	}
+
+	g.updateStats(bestMatch.score)
+
+	return &MatchResult{
+		MatchID:    NewMatchID(fmt.Sprintf("match_%d_%d", player.ID, bestMatch.player.ID)),
+		Players:    []*Player{player, bestMatch.player},
+		Quality:    bestMatch.score,
+		Confidence: g.calculateConfidence(player, bestMatch.player),
+		Algorithm:  g.Name(),
+		Metadata: map[string]interface{}{
+			"calculation_time": time.Since(startTime),
+			"candidates_count": len(candidates),
+			"qualified_count":  len(scores),
+		},
+	}, nil
+}
+
+// FindTeamMatch 按对局格式为玩家组建两队对局
+func (g *GlickoAlgorithm) FindTeamMatch(ctx context.Context, player *Player, candidates []*Player, format TeamFormat) (*MatchResult, error) {
+	result, err := findTeamMatch(ctx, g, player, candidates, format)
+	if err != nil {
+		return nil, err
+	}
+	result.Confidence = 1.0
+	for _, p := range result.Players[1:] {
+		result.Confidence = math.Min(result.Confidence, g.calculateConfidence(player, p))
+	}
+	g.updateStats(result.Quality)
+	return result, nil
+}
+
+// FindBatchMatches 在整批排队玩家中全局优化组局，见 findBatchMatches
+func (g *GlickoAlgorithm) FindBatchMatches(ctx context.Context, players []*Player, options BatchOptions) ([]*MatchResult, error) {
+	results, err := findBatchMatches(ctx, g, players, options)
+	if err != nil {
+		return nil, err
+	}
+	for _, result := range results {
+		result.Confidence = 1.0
+		for _, p := range result.Players[1:] {
+			result.Confidence = math.Min(result.Confidence, g.calculateConfidence(result.Players[0], p))
+		}
+		g.updateStats(result.Quality)
+	}
+	return results, nil
+}
+
+// CalculateMMR 按对局更新玩家评级，同时更新玩家的 RatingDeviation、Volatility 和 RatingPeriodStart
+// 同一评级周期内的对局依次更新，只在周期的第一局计入波动率带来的RD增长，结果近似于周期末批量更新
+func (g *GlickoAlgorithm) CalculateMMR(ctx context.Context, player *Player, gameResult *GameResult) (float64, error) {
+	if err := g.ValidatePlayer(player); err != nil {
+		return 0, err
+	}
+	if gameResult == nil {
+		return 0, fmt.Errorf("game result cannot be nil")
+	}
+
+	gameTime := gameResult.GameTime
+	if gameTime.IsZero() {
+		gameTime = time.Now()
+	}
+
+	start, rd, newPeriod := g.currentPeriod(player, gameTime)
+	rating, rd, volatility := g.rate(g.ratingOf(player), rd, g.volatilityOf(player), gameResult, newPeriod)
+	player.RatingDeviation = rd
+	player.Volatility = volatility
+	player.RatingPeriodStart = start
+	return rating, nil
+}
+
+// PredictOutcome 两队分别视为评级取平均、RD取均方根的一名玩家，按 Glicko-2 期望得分预测获胜概率；
+// MMR变化为对阵对方队伍的单局评级更新，不计波动率的变化
+func (g *GlickoAlgorithm) PredictOutcome(ctx context.Context, teams []*Team) (*MatchPrediction, error) {
+	if err := validatePredictionTeams(teams); err != nil {
+		return nil, err
+	}
+
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	ratingA, rdA := g.teamRating(teams[0].Players)
+	ratingB, rdB := g.teamRating(teams[1].Players)
+	winA := glickoE((ratingA-glickoBase)/glickoScale, (ratingB-glickoBase)/glickoScale, glickoG(math.Hypot(rdA, rdB)/glickoScale))
+
+	return newMatchPrediction(g.config.Name, teams, winA, func(player *Player, opponents []*Player) (float64, float64) {
+		rating, rd := g.teamRating(opponents)
+		mu := (g.ratingOf(player) - glickoBase) / glickoScale
+		gPhi := glickoG(rd / glickoScale)
+		expected := glickoE(mu, (rating-glickoBase)/glickoScale, gPhi)
+
+		phi := g.rdOf(player) / glickoScale
+		sigma := g.volatilityOf(player)
+		v := 1 / (gPhi * gPhi * expected * (1 - expected))
+		newPhiSq := 1 / (1/(phi*phi+sigma*sigma) + 1/v)
+		return newPhiSq * gPhi * (1 - expected) * glickoScale, -newPhiSq * gPhi * expected * glickoScale
+	}), nil
+}
+
+func (g *GlickoAlgorithm) ValidatePlayer(player *Player) error {
+	return validatePlayer(player)
+}
+
+// 根据玩家保存的周期开始时间确定对局所在的评级周期，返回周期开始时间、对局前的RD以及是否开启了新周期
+// 跨周期时按周期长度对齐，并对中间没有对局的周期增大RD，玩家长期不活跃后RD回升到初始值
+func (g *GlickoAlgorithm) currentPeriod(player *Player, gameTime time.Time) (time.Time, float64, bool) {
+	start := player.RatingPeriodStart
+	rd := g.rdOf(player)
+	if start.IsZero() {
+		return gameTime, rd, true
+	}
+	length := g.periodLength()
+	if gameTime.Before(start.Add(length)) {
+		return start, rd, false
+	}
+
+	elapsed := int(gameTime.Sub(start) / length)
+	sigma := g.volatilityOf(player)
+	phi := rd / glickoScale
+	for i := 1; i < elapsed; i++ {
+		phi = math.Sqrt(phi*phi + sigma*sigma)
+	}
+	return start.Add(time.Duration(elapsed) * length), math.Min(phi*glickoScale, g.initialRD()), true
+}
+
+// Glicko-2 单局评级更新，newPeriod 为 true 时先按新的波动率增大RD
+func (g *GlickoAlgorithm) rate(rating, rd, sigma float64, result *GameResult, newPeriod bool) (float64, float64, float64) {
+	mu := (rating - glickoBase) / glickoScale
+	phi := rd / glickoScale
+
+	opponentRD := result.OpponentRD
+	if opponentRD <= 0 {
+		opponentRD = g.initialRD()
+	}
+	muJ := (result.OpponentMMR - glickoBase) / glickoScale
+	gPhi := glickoG(opponentRD / glickoScale)
+	expected := glickoE(mu, muJ, gPhi)
+
+	score := 0.0
+	if result.IsWin {
+		score = 1.0
+	}
+	v := 1 / (gPhi * gPhi * expected * (1 - expected))
+	deltaSum := gPhi * (score - expected)
+	delta := v * deltaSum
+
+	newSigma := g.newVolatility(sigma, phi, v, delta)
+	phiStar := phi
+	if newPeriod {
+		phiStar = math.Sqrt(phi*phi + newSigma*newSigma)
+	}
+	newPhi := 1 / math.Sqrt(1/(phiStar*phiStar)+1/v)
+	newMu := mu + newPhi*newPhi*deltaSum
+
+	return newMu*glickoScale + glickoBase, newPhi * glickoScale, newSigma
+}
+
+// 使用 Illinois 算法求解新的波动率
+func (g *GlickoAlgorithm) newVolatility(sigma, phi, v, delta float64) float64 {
+	tau := floatParam(g.config, "tau", 0.5)
+	a := math.Log(sigma * sigma)
+	f := func(x float64) float64 {
+		ex := math.Exp(x)
+		d := phi*phi + v + ex
+		return ex*(delta*delta-phi*phi-v-ex)/(2*d*d) - (x-a)/(tau*tau)
+	}
+
+	A := a
+	var B float64
+	if delta*delta > phi*phi+v {
+		B = math.Log(delta*delta - phi*phi - v)
+	} else {
+		k := 1.0
+		for f(a-k*tau) < 0 {
+			k++
+		}
+		B = a - k*tau
+	}
+
+	fA, fB := f(A), f(B)
+	for math.Abs(B-A) > glickoEpsilon {
+		C := A + (A-B)*fA/(fB-fA)
+		fC := f(C)
+		if fC*fB <= 0 {
+			A, fA = B, fB
+		} else {
+			fA /= 2
+		}
+		B, fB = C, fC
+	}
+	return math.Exp(A / 2)
+}
+
+// 基于双方RD计算预期胜率，越接近0.5匹配度越高
+// RD越大，g(φ)越小，评级差对预期胜率的影响越弱
+func (g *GlickoAlgorithm) calculateMMRScore(p1, p2 *Player) float64 {
+	mu1 := (g.ratingOf(p1) - glickoBase) / glickoScale
+	mu2 := (g.ratingOf(p2) - glickoBase) / glickoScale
+	phi1 := g.rdOf(p1) / glickoScale
+	phi2 := g.rdOf(p2) / glickoScale
+
+	expected := glickoE(mu1, mu2, glickoG(math.Sqrt(phi1*phi1+phi2*phi2)))
+	return 1.0 - 2*math.Abs(expected-0.5)
+}
+
+// 置信度取决于双方中RD较大的一方
+func (g *GlickoAlgorithm) calculateConfidence(p1, p2 *Player) float64 {
+	maxRD := math.Max(g.rdOf(p1), g.rdOf(p2))
+	return math.Max(0, math.Min(1, 1.0-maxRD/g.initialRD()))
+}
+
+func (g *GlickoAlgorithm) ratingOf(p *Player) float64 {
+	if p.MMR <= 0 {
+		return floatParam(g.config, "initial_rating", glickoBase)
+	}
+	return p.MMR
+}
+
+// 队伍的综合评级：平均评级和均方根RD
+func (g *GlickoAlgorithm) teamRating(players []*Player) (float64, float64) {
+	var rating, rdSq float64
+	for _, p := range players {
+		rating += g.ratingOf(p)
+		rdSq += g.rdOf(p) * g.rdOf(p)
+	}
+	n := float64(len(players))
+	return rating / n, math.Sqrt(rdSq / n)
+}
+
+func (g *GlickoAlgorithm) rdOf(p *Player) float64 {
+	if p.RatingDeviation <= 0 {
+		return g.initialRD()
+	}
+	return p.RatingDeviation
+}
+
+func (g *GlickoAlgorithm) volatilityOf(p *Player) float64 {
+	if p.Volatility <= 0 {
+		return floatParam(g.config, "volatility", 0.06)
+	}
+	return p.Volatility
+}
+
+func (g *GlickoAlgorithm) initialRD() float64 {
+	return floatParam(g.config, "initial_rd", 350)
+}
+
+// 评级周期长度，配置不大于0时使用默认的24小时
+func (g *GlickoAlgorithm) periodLength() time.Duration {
+	hours := floatParam(g.config, "rating_period_hours", 24)
+	if length := time.Duration(hours * float64(time.Hour)); length > 0 {
+		return length
+	}
+	return 24 * time.Hour
+}
+
+func (g *GlickoAlgorithm) updateStats(quality float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	recordMatchQuality(g.stats, quality)
+}
+
+func (g *GlickoAlgorithm) GetStats() *AlgorithmStats {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return g.stats.snapshot()
+}
+
+func (g *GlickoAlgorithm) ResetStats() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.stats = newAlgorithmStats()
+}
+
+func glickoG(phi float64) float64 {
+	return 1 / math.Sqrt(1+3*phi*phi/(math.Pi*math.Pi))
+}
+
+func glickoE(mu, muJ, gPhiJ float64) float64 {
+	return 1 / (1 + math.Exp(-gPhiJ*(mu-muJ)))
+}
@@ -0,0 +1,183 @@
+package algorithm
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/mangooer/gamehub-arena/internal/config"
+)
+
+func newTestGlicko(params map[string]interface{}) *GlickoAlgorithm {
+	return NewGlickoAlgorithm(&config.AlgorithmConfig{Name: "glicko2", Parameters: params})
+}
+
+func TestGlickoNewVolatility(t *testing.T) {
+	tests := []struct {
+		name   string
+		tau    float64
+		sigma  float64
+		phi    float64
+		v      float64
+		delta  float64
+		want   float64
+		within float64
+	}{
+		{
+			// Glickman《Example of the Glicko-2 system》中的示例
+			name:   "论文示例",
+			tau:    0.5,
+			sigma:  0.06,
+			phi:    200 / glickoScale,
+			v:      1.7785,
+			delta:  -0.4834,
+			want:   0.05999,
+			within: 1e-5,
+		},
+		{
+			name:   "结果符合预期时波动率几乎不变",
+			tau:    0.5,
+			sigma:  0.06,
+			phi:    50 / glickoScale,
+			v:      10,
+			delta:  0,
+			want:   0.06,
+			within: 1e-4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGlicko(map[string]interface{}{"tau": tt.tau})
+			if got := g.newVolatility(tt.sigma, tt.phi, tt.v, tt.delta); math.Abs(got-tt.want) > tt.within {
+				t.Errorf("newVolatility() = %.6f, want %.6f", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGlickoNewVolatilityGrowsWithSurprise(t *testing.T) {
+	g := newTestGlicko(map[string]interface{}{"tau": 0.5})
+	phi, v := 50/glickoScale, 2.0
+	expected := g.newVolatility(0.06, phi, v, 0.1)
+	upset := g.newVolatility(0.06, phi, v, 3)
+	if upset <= expected {
+		t.Errorf("volatility after upset %.6f should exceed %.6f", upset, expected)
+	}
+}
+
+func TestGlickoRate(t *testing.T) {
+	g := newTestGlicko(nil)
+	tests := []struct {
+		name      string
+		result    GameResult
+		newPeriod bool
+		wantUp    bool
+	}{
+		{name: "战胜同分对手", result: GameResult{IsWin: true, OpponentMMR: 1500, OpponentRD: 50}, wantUp: true},
+		{name: "输给同分对手", result: GameResult{IsWin: false, OpponentMMR: 1500, OpponentRD: 50}, wantUp: false},
+		{name: "新周期战胜同分对手", result: GameResult{IsWin: true, OpponentMMR: 1500, OpponentRD: 50}, newPeriod: true, wantUp: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rating, rd, sigma := g.rate(1500, 100, 0.06, &tt.result, tt.newPeriod)
+			if (rating > 1500) != tt.wantUp {
+				t.Errorf("rate() rating = %.2f, want up = %v", rating, tt.wantUp)
+			}
+			if rd >= 100 {
+				t.Errorf("rate() rd = %.2f, should shrink after a game", rd)
+			}
+			if sigma <= 0 {
+				t.Errorf("rate() sigma = %.6f, want positive", sigma)
+			}
+		})
+	}
+
+	// 新周期先按波动率增大RD，单局后的RD应大于同一周期内的结果
+	result := GameResult{IsWin: true, OpponentMMR: 1500, OpponentRD: 50}
+	_, samePeriodRD, _ := g.rate(1500, 100, 0.06, &result, false)
+	_, newPeriodRD, _ := g.rate(1500, 100, 0.06, &result, true)
+	if newPeriodRD <= samePeriodRD {
+		t.Errorf("new period rd %.4f should exceed same period rd %.4f", newPeriodRD, samePeriodRD)
+	}
+}
+
+func TestGlickoCurrentPeriod(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	tests := []struct {
+		name          string
+		params        map[string]interface{}
+		start         time.Time
+		rd            float64
+		wantStart     time.Time
+		wantRD        float64
+		wantNewPeriod bool
+	}{
+		{
+			name:          "首局开启新周期",
+			wantStart:     now,
+			rd:            80,
+			wantRD:        80,
+			wantNewPeriod: true,
+		},
+		{
+			name:      "仍在当前周期内",
+			start:     now.Add(-time.Hour),
+			rd:        80,
+			wantStart: now.Add(-time.Hour),
+			wantRD:    80,
+		},
+		{
+			name:          "跨过一个周期时RD留给 rate 增大",
+			start:         now.Add(-day - time.Hour),
+			rd:            80,
+			wantStart:     now.Add(-time.Hour),
+			wantRD:        80,
+			wantNewPeriod: true,
+		},
+		{
+			name:          "跨过多个周期时补上空闲周期的增长",
+			start:         now.Add(-3*day - time.Hour),
+			rd:            80,
+			wantStart:     now.Add(-time.Hour),
+			wantRD:        math.Sqrt(80*80 + 2*(0.06*glickoScale)*(0.06*glickoScale)),
+			wantNewPeriod: true,
+		},
+		{
+			name:          "长期未对局时RD不超过初始值",
+			start:         now.Add(-2000 * day),
+			rd:            80,
+			wantStart:     now,
+			wantRD:        350,
+			wantNewPeriod: true,
+		},
+		{
+			name:          "周期长度不大于0时按24小时计算",
+			params:        map[string]interface{}{"rating_period_hours": 0.0},
+			start:         now.Add(-day - time.Hour),
+			rd:            80,
+			wantStart:     now.Add(-time.Hour),
+			wantRD:        80,
+			wantNewPeriod: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGlicko(tt.params)
+			player := &Player{RatingDeviation: tt.rd, Volatility: 0.06, RatingPeriodStart: tt.start}
+			start, rd, newPeriod := g.currentPeriod(player, now)
+			if !start.Equal(tt.wantStart) {
+				t.Errorf("currentPeriod() start = %v, want %v", start, tt.wantStart)
+			}
+			if math.Abs(rd-tt.wantRD) > 1e-9 {
+				t.Errorf("currentPeriod() rd = %.4f, want %.4f", rd, tt.wantRD)
+			}
+			if newPeriod != tt.wantNewPeriod {
+				t.Errorf("currentPeriod() newPeriod = %v, want %v", newPeriod, tt.wantNewPeriod)
+			}
+		})
+	}
+}
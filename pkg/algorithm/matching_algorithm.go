@@ -8,21 +8,54 @@ import (
 )
 
 type Player struct {
-	ID        uint64    `json:"id"`
-	Username  string    `json:"username"`
-	Level     int       `json:"level"`
-	Rank      string    `json:"rank"`
-	WinRate   float64   `json:"win_rate"`
-	WinCount  int       `json:"win_count"`
-	LoseCount int       `json:"lose_count"`
-	Ping      int       `json:"ping"`
-	QueueTime time.Time `json:"queue_time"`
-	GameMode  string    `json:"game_mode"`
-	Region    string    `json:"region"`
+	ID        uint64         `json:"id"`
+	Username  string         `json:"username"`
+	Level     int            `json:"level"`
+	Rank      string         `json:"rank"`
+	WinRate   float64        `json:"win_rate"`
+	WinCount  int            `json:"win_count"`
+	LoseCount int            `json:"lose_count"`
+	Ping      int            `json:"ping"`
+	Pings     map[string]int `json:"pings,omitempty"` //到各机房的延迟（毫秒）
+	QueueTime time.Time      `json:"queue_time"`
+	GameMode  string         `json:"game_mode"`
+	Region    string         `json:"region"`
 	// 扩展字段，用于算法计算
 	MMR         float64      `json:"mmr"`          //匹配评级
 	Confidence  float64      `json:"confidence"`   //评级置信度
 	RecentGames []GameResult `json:"recent_games"` //最近游戏结果
+
+	// Glicko-2 评级状态
+	RatingDeviation   float64   `json:"rating_deviation,omitempty"` //评级偏差RD
+	Volatility        float64   `json:"volatility,omitempty"`       //评级波动率
+	RatingPeriodStart time.Time `json:"rating_period_start"`        //当前评级周期的开始时间，为零值时下一局开启新周期
+
+	// TrueSkill 评级状态
+	Mu    float64 `json:"mu,omitempty"`    //技能均值
+	Sigma float64 `json:"sigma,omitempty"` //技能标准差
+
+	// 角色选择，按偏好排序，可包含 fill；为空视为补位
+	Roles   []string           `json:"roles,omitempty"`    //角色偏好
+	RoleMMR map[string]float64 `json:"role_mmr,omitempty"` //各角色MMR，缺少时使用MMR
+
+	AllowBackfill bool `json:"allow_backfill,omitempty"` //是否愿意补位进行中的对局
+
+	// 排队优先级，由队列在入队和放回队列时设置
+	PriorityScore float64 `json:"priority_score,omitempty"` //优先级评分，越高越先组局，对应 match_queue.priority_score
+	LowPriority   bool    `json:"low_priority,omitempty"`   //处于低优先级队列，只与同样处于低优先级队列的玩家匹配
+
+	// 组队信息，单人排队时为空
+	PartyID   string  `json:"party_id,omitempty"`   //所属队伍ID
+	PartySize int     `json:"party_size,omitempty"` //队伍人数
+	PartyMMR  float64 `json:"party_mmr,omitempty"`  //队伍综合MMR（含预组队加成）
+}
+
+// EffectiveMMR 匹配时使用的MMR，组队玩家使用队伍综合MMR
+func (p *Player) EffectiveMMR() float64 {
+	if p.PartyID != "" && p.PartyMMR > 0 {
+		return p.PartyMMR
+	}
+	return p.MMR
 }
 
 // 近期游戏结果
@@ -30,6 +63,7 @@ type GameResult struct {
 	IsWin       bool      `json:"is_win"`
 	GameTime    time.Time `json:"game_time"`
 	OpponentMMR float64   `json:"opponent_mmr"`
+	OpponentRD  float64   `json:"opponent_rd,omitempty"` //对手评级偏差（Glicko-2）
 	Performance float64   `json:"performance"`
 }
 
@@ -37,10 +71,38 @@ type GameResult struct {
 type MatchResult struct {
 	MatchID    string                 `json:"match_id"`
 	Players    []*Player              `json:"players"`
-	Quality    float64                `json:"quality"`    //匹配质量
-	Confidence float64                `json:"confidence"` //匹配置信度
-	Algorithm  string                 `json:"algorithm"`  //匹配算法
-	Metadata   map[string]interface{} `json:"metadata"`   //匹配元数据
+	Teams      []*Team                `json:"teams,omitempty"`       //分队结果
+	DataCenter string                 `json:"data_center,omitempty"` //对局所在机房
+	Quality    float64                `json:"quality"`               //匹配质量
+	Confidence float64                `json:"confidence"`            //匹配置信度
+	Algorithm  string                 `json:"algorithm"`             //匹配算法
+	Prediction *MatchPrediction       `json:"prediction,omitempty"`  //胜负预测
+	Metadata   map[string]interface{} `json:"metadata"`              //匹配元数据
+	CreatedAt  time.Time              `json:"created_at"`            //成局时间
+}
+
+// RoleOf 返回玩家分配到的角色，未分角色时返回空字符串
+func (r *MatchResult) RoleOf(playerID uint64) string {
+	for _, team := range r.Teams {
+		for i, p := range team.Players {
+			if p.ID == playerID && i < len(team.Roles) {
+				return team.Roles[i]
+			}
+		}
+	}
+	return ""
+}
+
+// TeamOf 返回玩家所在队伍名称，未分队时返回空字符串
+func (r *MatchResult) TeamOf(playerID uint64) string {
+	for _, team := range r.Teams {
+		for _, p := range team.Players {
+			if p.ID == playerID {
+				return team.Name
+			}
+		}
+	}
+	return ""
 }
 
 // 算法统计信息
@@ -70,7 +132,12 @@ type MatchingAlgorithm interface {
 	// 核心匹配功能
 	CalculateMatchScore(ctx context.Context, p1, p2 *Player) (float64, error)
 	FindOptimalMatch(ctx context.Context, player *Player, candidates []*Player) (*MatchResult, error)
+	FindTeamMatch(ctx context.Context, player *Player, candidates []*Player, format TeamFormat) (*MatchResult, error)
+	// 在整批排队玩家中组建使对局质量总和最大的一组对局，玩家不会出现在多个对局中
+	FindBatchMatches(ctx context.Context, players []*Player, options BatchOptions) ([]*MatchResult, error)
 	CalculateMMR(ctx context.Context, player *Player, gameResult *GameResult) (float64, error)
+	// 预测两队的获胜概率和每名玩家胜负时的MMR变化
+	PredictOutcome(ctx context.Context, teams []*Team) (*MatchPrediction, error)
 
 	// 配置和调优
 	SetConfig(config *config.AlgorithmConfig) error
@@ -81,3 +148,13 @@ type MatchingAlgorithm interface {
 	GetStats() *AlgorithmStats
 	ResetStats()
 }
+
+// 团队评级算法接口，用于5v5等多人团队模式
+type TeamRatingAlgorithm interface {
+	MatchingAlgorithm
+
+	// 评估一组队伍对阵的匹配质量（0-1）
+	CalculateTeamMatchQuality(ctx context.Context, teams [][]*Player) (float64, error)
+	// 根据队伍名次更新所有玩家评级，名次越小越靠前，名次相同视为平局
+	UpdateTeamRatings(ctx context.Context, teams [][]*Player, ranks []int) error
+}
@@ -0,0 +1,69 @@
+package algorithm
+
+import "fmt"
+
+// 预组队：一组好友作为一个整体排队，只会被分到同一队伍
+type Party struct {
+	ID       string    `json:"id"`
+	LeaderID uint64    `json:"leader_id"`
+	Members  []*Player `json:"members"`
+}
+
+// PartyMMR 队伍综合MMR：成员平均MMR加上预组队加成
+// 预组队的配合优势随人数增加，每多一名成员增加 premadeBonus
+func PartyMMR(members []*Player, premadeBonus float64) float64 {
+	if len(members) == 0 {
+		return 0
+	}
+	return averageMMR(members) + premadeBonus*float64(len(members)-1)
+}
+
+// Validate 校验队伍成员完整且队长在队伍中
+func (p *Party) Validate() error {
+	if p.ID == "" {
+		return fmt.Errorf("party ID cannot be empty")
+	}
+	if len(p.Members) == 0 {
+		return fmt.Errorf("party %s has no members", p.ID)
+	}
+	hasLeader := false
+	seen := make(map[uint64]bool, len(p.Members))
+	for _, member := range p.Members {
+		if err := validatePlayer(member); err != nil {
+			return fmt.Errorf("party %s member validation failed: %w", p.ID, err)
+		}
+		if seen[member.ID] {
+			return fmt.Errorf("party %s has duplicate member %d", p.ID, member.ID)
+		}
+		seen[member.ID] = true
+		if member.GameMode != p.Members[0].GameMode {
+			return fmt.Errorf("party %s members queue for different game modes", p.ID)
+		}
+		if member.ID == p.LeaderID {
+			hasLeader = true
+		}
+	}
+	if !hasLeader {
+		return fmt.Errorf("party %s leader %d is not a member", p.ID, p.LeaderID)
+	}
+	return nil
+}
+
+// 按组队拆分为匹配单元，保持首次出现的顺序；单人玩家各自为一个单元
+func groupUnits(players []*Player) [][]*Player {
+	var units [][]*Player
+	index := make(map[string]int)
+	for _, p := range players {
+		if p.PartyID == "" {
+			units = append(units, []*Player{p})
+			continue
+		}
+		if i, ok := index[p.PartyID]; ok {
+			units[i] = append(units[i], p)
+			continue
+		}
+		index[p.PartyID] = len(units)
+		units = append(units, []*Player{p})
+	}
+	return units
+}
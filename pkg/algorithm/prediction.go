@@ -0,0 +1,81 @@
+package algorithm
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+var ErrUnsupportedPrediction = errors.New("unsupported match prediction")
+
+// 对局结果预测，由选中的评级模型给出，用于与实际对局结果对比
+type MatchPrediction struct {
+	Model          string             `json:"model"`           //预测使用的评级模型
+	WinProbability map[string]float64 `json:"win_probability"` //各队获胜概率，按队伍名称
+	Fairness       float64            `json:"fairness"`        //公平度 1-|P(A)-P(B)|，1为完全势均力敌
+	Players        []PlayerPrediction `json:"players"`         //每名玩家的预期MMR变化
+}
+
+// 玩家在本局的预测
+type PlayerPrediction struct {
+	PlayerID       uint64  `json:"player_id"`
+	Team           string  `json:"team"`
+	WinProbability float64 `json:"win_probability"` //所在队伍获胜概率
+	MMRGain        float64 `json:"mmr_gain"`        //获胜时的预期MMR变化
+	MMRLoss        float64 `json:"mmr_loss"`        //失败时的预期MMR变化（非正）
+}
+
+// ExpectedChange 按获胜概率加权的MMR期望变化
+func (p PlayerPrediction) ExpectedChange() float64 {
+	return p.WinProbability*p.MMRGain + (1-p.WinProbability)*p.MMRLoss
+}
+
+// 预测只支持两队对阵
+func validatePredictionTeams(teams []*Team) error {
+	if len(teams) != 2 {
+		return fmt.Errorf("%w: expected 2 teams, got %d", ErrUnsupportedPrediction, len(teams))
+	}
+	for i, team := range teams {
+		if team == nil || len(team.Players) == 0 {
+			return fmt.Errorf("%w: team %d is empty", ErrUnsupportedPrediction, i)
+		}
+	}
+	return nil
+}
+
+// 根据第一队的获胜概率组装预测，outcome 给出玩家对阵 opponents 时胜、负的MMR变化
+func newMatchPrediction(model string, teams []*Team, winA float64, outcome func(player *Player, opponents []*Player) (float64, float64)) *MatchPrediction {
+	winA = math.Max(0, math.Min(1, winA))
+	probability := []float64{winA, 1 - winA}
+
+	prediction := &MatchPrediction{
+		Model:          model,
+		WinProbability: make(map[string]float64, len(teams)),
+		Fairness:       1 - math.Abs(probability[0]-probability[1]),
+	}
+	for i, team := range teams {
+		prediction.WinProbability[team.Name] = probability[i]
+		opponents := teams[1-i].Players
+		for _, p := range team.Players {
+			gain, loss := outcome(p, opponents)
+			prediction.Players = append(prediction.Players, PlayerPrediction{
+				PlayerID:       p.ID,
+				Team:           team.Name,
+				WinProbability: probability[i],
+				MMRGain:        gain,
+				MMRLoss:        loss,
+			})
+		}
+	}
+	return prediction
+}
+
+// ForPlayer 返回玩家的预测，不存在时返回 false
+func (m *MatchPrediction) ForPlayer(playerID uint64) (PlayerPrediction, bool) {
+	for _, p := range m.Players {
+		if p.PlayerID == playerID {
+			return p, true
+		}
+	}
+	return PlayerPrediction{}, false
+}
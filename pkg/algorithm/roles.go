@@ -0,0 +1,133 @@
+package algorithm
+
+import "math"
+
+// 补位：可以担任任意角色
+const RoleFill = "fill"
+
+// 团队对局格式
+type TeamFormat struct {
+	Size     int                          `json:"size"`            // 每队人数
+	Roles    []string                     `json:"roles,omitempty"` // 每队必须各有一名的角色，为空时不分角色
+	Feasible func(players []*Player) bool `json:"-"`               // 满员对局的额外约束（如有满足延迟上限的机房），为空时不限制
+}
+
+// RoleCost 玩家担任角色的偏好代价：角色在偏好列表中的位置，
+// 未列出时取 fill 的位置；没有填写偏好视为补位且代价为0。无法担任时返回 false
+func (p *Player) RoleCost(role string) (int, bool) {
+	if len(p.Roles) == 0 {
+		return 0, true
+	}
+	fill := -1
+	for i, preferred := range p.Roles {
+		if preferred == role {
+			return i, true
+		}
+		if preferred == RoleFill && fill < 0 {
+			fill = i
+		}
+	}
+	if fill >= 0 {
+		return fill, true
+	}
+	return 0, false
+}
+
+// MMRFor 玩家担任角色时的MMR：有该角色MMR时使用角色MMR，并保留预组队加成
+func (p *Player) MMRFor(role string) float64 {
+	roleMMR, ok := p.RoleMMR[role]
+	if !ok || role == "" {
+		return p.EffectiveMMR()
+	}
+	return roleMMR + p.EffectiveMMR() - p.MMR
+}
+
+// 为一支队伍分配角色，使偏好代价之和最小，代价相同时角色MMR之和更大者优先。
+// 返回与 players 对应的角色；无法让每个角色恰好一人时返回 false
+func assignRoles(players []*Player, roles []string) ([]string, int, bool) {
+	if len(players) != len(roles) {
+		return nil, 0, false
+	}
+	n := len(roles)
+	assignment := make([]int, n)
+	best := make([]int, n)
+	used := make([]bool, n)
+	bestCost, bestMMR := math.MaxInt, math.Inf(-1)
+
+	var search func(i, cost int, mmr float64)
+	search = func(i, cost int, mmr float64) {
+		if cost > bestCost {
+			return
+		}
+		if i == n {
+			if cost < bestCost || mmr > bestMMR {
+				bestCost, bestMMR = cost, mmr
+				copy(best, assignment)
+			}
+			return
+		}
+		for r := 0; r < n; r++ {
+			if used[r] {
+				continue
+			}
+			c, ok := players[i].RoleCost(roles[r])
+			if !ok {
+				continue
+			}
+			used[r] = true
+			assignment[i] = r
+			search(i+1, cost+c, mmr+players[i].MMRFor(roles[r]))
+			used[r] = false
+		}
+	}
+	search(0, 0, 0)
+	if bestCost == math.MaxInt {
+		return nil, 0, false
+	}
+
+	assigned := make([]string, n)
+	for i, r := range best {
+		assigned[i] = roles[r]
+	}
+	return assigned, bestCost, true
+}
+
+// 对局中的玩家能否填满两队的所有角色位置（每个角色两个位置），用于组建对局时提前排除
+// 使用二分图增广路匹配，玩家数不足时只检查已有玩家能否各自占到一个位置
+func rolesFeasible(players []*Player, roles []string) bool {
+	if len(roles) == 0 {
+		return true
+	}
+	slots := 2 * len(roles)
+	if len(players) > slots {
+		return false
+	}
+	owner := make([]int, slots)
+	for i := range owner {
+		owner[i] = -1
+	}
+
+	var augment func(p int, visited []bool) bool
+	augment = func(p int, visited []bool) bool {
+		for s := 0; s < slots; s++ {
+			if visited[s] {
+				continue
+			}
+			if _, ok := players[p].RoleCost(roles[s/2]); !ok {
+				continue
+			}
+			visited[s] = true
+			if owner[s] < 0 || augment(owner[s], visited) {
+				owner[s] = p
+				return true
+			}
+		}
+		return false
+	}
+	for p := range players {
+		if !augment(p, make([]bool, slots)) {
+			return false
+		}
+	}
+	return true
+}
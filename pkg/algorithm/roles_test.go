@@ -0,0 +1,217 @@
+package algorithm
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestAssignRoles(t *testing.T) {
+	roles := []string{"tank", "healer", "dps"}
+	tests := []struct {
+		name      string
+		players   []*Player
+		wantRoles []string
+		wantCost  int
+		wantOK    bool
+	}{
+		{
+			name: "每人首选不同角色",
+			players: []*Player{
+				{ID: 1, MMR: 1500, Roles: []string{"dps"}},
+				{ID: 2, MMR: 1500, Roles: []string{"tank"}},
+				{ID: 3, MMR: 1500, Roles: []string{"healer"}},
+			},
+			wantRoles: []string{"dps", "tank", "healer"},
+			wantCost:  0,
+			wantOK:    true,
+		},
+		{
+			name: "首选冲突时代价最小",
+			players: []*Player{
+				{ID: 1, MMR: 1500, Roles: []string{"dps", "tank"}},
+				{ID: 2, MMR: 1500, Roles: []string{"dps"}},
+				{ID: 3, MMR: 1500, Roles: []string{"healer"}},
+			},
+			wantRoles: []string{"tank", "dps", "healer"},
+			wantCost:  1,
+			wantOK:    true,
+		},
+		{
+			name: "未列出的角色按fill的位置计算代价",
+			players: []*Player{
+				{ID: 1, MMR: 1500, Roles: []string{"dps", "fill"}},
+				{ID: 2, MMR: 1500, Roles: []string{"dps"}},
+				{ID: 3, MMR: 1500, Roles: []string{"tank"}},
+			},
+			wantRoles: []string{"healer", "dps", "tank"},
+			wantCost:  1,
+			wantOK:    true,
+		},
+		{
+			name: "代价相同时角色MMR之和更大者优先",
+			players: []*Player{
+				{ID: 1, MMR: 1500, RoleMMR: map[string]float64{"tank": 1400, "dps": 1700}},
+				{ID: 2, MMR: 1500, RoleMMR: map[string]float64{"tank": 1600, "dps": 1300}},
+				{ID: 3, MMR: 1500, Roles: []string{"healer"}},
+			},
+			wantRoles: []string{"dps", "tank", "healer"},
+			wantCost:  0,
+			wantOK:    true,
+		},
+		{
+			name: "没有人能担任的角色",
+			players: []*Player{
+				{ID: 1, MMR: 1500, Roles: []string{"dps"}},
+				{ID: 2, MMR: 1500, Roles: []string{"dps"}},
+				{ID: 3, MMR: 1500, Roles: []string{"tank"}},
+			},
+			wantOK: false,
+		},
+		{
+			name: "人数与角色数不一致",
+			players: []*Player{
+				{ID: 1, MMR: 1500},
+				{ID: 2, MMR: 1500},
+			},
+			wantOK: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, cost, ok := assignRoles(tt.players, roles)
+			if ok != tt.wantOK {
+				t.Fatalf("assignRoles() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.wantRoles) {
+				t.Errorf("assignRoles() roles = %v, want %v", got, tt.wantRoles)
+			}
+			if cost != tt.wantCost {
+				t.Errorf("assignRoles() cost = %d, want %d", cost, tt.wantCost)
+			}
+		})
+	}
+}
+
+func TestBalanceRoleTeams(t *testing.T) {
+	solo := func(id uint64, mmr float64, roles ...string) *Player {
+		return &Player{ID: id, MMR: mmr, Roles: roles}
+	}
+	party := func(id uint64, mmr float64, partyID string, size int) *Player {
+		return &Player{ID: id, MMR: mmr, PartyID: partyID, PartySize: size}
+	}
+
+	tests := []struct {
+		name    string
+		players []*Player
+		format  TeamFormat
+		wantGap float64
+		// 必须在同一队的玩家
+		together [][]uint64
+		wantErr  bool
+	}{
+		{
+			name: "不分角色时平均MMR差最小",
+			players: []*Player{
+				solo(1, 1000), solo(2, 1100), solo(3, 1200),
+				solo(4, 1300), solo(5, 1400), solo(6, 1500),
+			},
+			format:  TeamFormat{Size: 3},
+			wantGap: 100.0 / 3,
+		},
+		{
+			name: "预组队不被拆散",
+			players: []*Player{
+				party(1, 2000, "p1", 2), party(2, 2000, "p1", 2),
+				solo(3, 1500), solo(4, 1500), solo(5, 1500), solo(6, 1500),
+			},
+			format:   TeamFormat{Size: 3},
+			wantGap:  1000.0 / 3,
+			together: [][]uint64{{1, 2}},
+		},
+		{
+			name: "分角色时每队每个角色恰好一人",
+			players: []*Player{
+				solo(1, 1800, "tank"), solo(2, 1200, "tank"),
+				solo(3, 1500, "healer"), solo(4, 1500, "healer"),
+				solo(5, 1200, "dps"), solo(6, 1800, "dps"),
+			},
+			format:   TeamFormat{Size: 3, Roles: []string{"tank", "healer", "dps"}},
+			wantGap:  0,
+			together: [][]uint64{{1, 5}, {2, 6}},
+		},
+		{
+			name: "无法让两队都完成角色分配",
+			players: []*Player{
+				solo(1, 1500, "tank"), solo(2, 1500, "tank"), solo(3, 1500, "tank"),
+				solo(4, 1500, "healer"), solo(5, 1500, "dps"), solo(6, 1500, "dps"),
+			},
+			format:  TeamFormat{Size: 3, Roles: []string{"tank", "healer", "dps"}},
+			wantErr: true,
+		},
+		{
+			name:    "人数不足",
+			players: []*Player{solo(1, 1500), solo(2, 1500), solo(3, 1500)},
+			format:  TeamFormat{Size: 2},
+			wantErr: true,
+		},
+		{
+			name: "角色数与队伍人数不一致",
+			players: []*Player{
+				solo(1, 1500), solo(2, 1500), solo(3, 1500), solo(4, 1500),
+			},
+			format:  TeamFormat{Size: 2, Roles: []string{"tank", "healer", "dps"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			teams, err := BalanceRoleTeams(tt.players, tt.format)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("BalanceRoleTeams() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(teams) != 2 || teams[0].Name != TeamA || teams[1].Name != TeamB {
+				t.Fatalf("BalanceRoleTeams() returned unexpected teams %+v", teams)
+			}
+
+			teamOf := make(map[uint64]string)
+			for _, team := range teams {
+				if len(team.Players) != tt.format.Size {
+					t.Errorf("%s has %d players, want %d", team.Name, len(team.Players), tt.format.Size)
+				}
+				if len(tt.format.Roles) > 0 && !reflect.DeepEqual(team.Roles, tt.format.Roles) {
+					t.Errorf("%s roles = %v, want %v", team.Name, team.Roles, tt.format.Roles)
+				}
+				for i, p := range team.Players {
+					teamOf[p.ID] = team.Name
+					if len(tt.format.Roles) > 0 {
+						if _, ok := p.RoleCost(team.Roles[i]); !ok {
+							t.Errorf("player %d assigned role %s outside preferences", p.ID, team.Roles[i])
+						}
+					}
+				}
+			}
+			if len(teamOf) != len(tt.players) {
+				t.Errorf("teams contain %d distinct players, want %d", len(teamOf), len(tt.players))
+			}
+			for _, ids := range tt.together {
+				for _, id := range ids[1:] {
+					if teamOf[id] != teamOf[ids[0]] {
+						t.Errorf("players %d and %d should be on the same team", ids[0], id)
+					}
+				}
+			}
+			if gap := math.Abs(teams[0].AverageMMR - teams[1].AverageMMR); math.Abs(gap-tt.wantGap) > 1e-9 {
+				t.Errorf("MMR gap = %v, want %v", gap, tt.wantGap)
+			}
+		})
+	}
+}
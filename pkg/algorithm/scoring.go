@@ -0,0 +1,149 @@
+package algorithm
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mangooer/gamehub-arena/internal/config"
+)
+
+// 各算法共用的基础评分因子
+
+func validatePlayer(player *Player) error {
+	if player == nil {
+		return fmt.Errorf("player cannot be nil")
+	}
+	if player.ID <= 0 {
+		return fmt.Errorf("invalid player ID: %d", player.ID)
+	}
+	if player.Level < 1 || player.Level > 100 {
+		return fmt.Errorf("invalid player level: %d", player.Level)
+	}
+	if player.WinRate < 0 || player.WinRate > 1 {
+		return fmt.Errorf("invalid win rate: %f", player.WinRate)
+	}
+	if player.Ping < 0 {
+		return fmt.Errorf("invalid ping: %d", player.Ping)
+	}
+
+	return nil
+}
+
+func levelScore(cfg *config.AlgorithmConfig, p1, p2 *Player) float64 {
+	levelDiff := math.Abs(float64(p1.Level - p2.Level))
+	maxDiff := float64(cfg.MaxLevelDiff)
+	if levelDiff > maxDiff {
+		return 0
+	}
+	return 1.0 - (levelDiff / maxDiff)
+}
+
+func winRateScore(cfg *config.AlgorithmConfig, p1, p2 *Player) float64 {
+	winRateDiff := math.Abs(p1.WinRate - p2.WinRate)
+	maxDiff := cfg.MaxWinRateDiff
+	if winRateDiff > maxDiff {
+		return 0
+	}
+	return 1.0 - (winRateDiff / maxDiff)
+}
+
+// 延迟得分基于两人在最佳共同机房下的较大延迟
+func pingScore(cfg *config.AlgorithmConfig, p1, p2 *Player) float64 {
+	ping := float64(PairPing(p1, p2))
+	maxPing := float64(cfg.MaxPingDiff)
+	if ping > maxPing {
+		return 0
+	}
+	return 1.0 - (ping / maxPing)
+}
+
+// 按配置权重合并各因子得分
+func weightedScore(cfg *config.AlgorithmConfig, level, winRate, ping, mmr float64) float64 {
+	return cfg.Weights["level"]*level + cfg.Weights["winrate"]*winRate + cfg.Weights["ping"]*ping + cfg.Weights["mmr"]*mmr
+}
+
+// 读取数值型算法参数，兼容配置文件中的整数与字符串写法
+func floatParam(cfg *config.AlgorithmConfig, name string, defaultValue float64) float64 {
+	switch v := cfg.Parameters[name].(type) {
+	case float64:
+		return v
+	case float32:
+		return float64(v)
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case string:
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f
+		}
+	}
+	return defaultValue
+}
+
+type scoredCandidate struct {
+	player *Player
+	score  float64
+}
+
+// 计算所有候选者的匹配分数，返回达到最低质量阈值的候选者
+func qualifiedCandidates(ctx context.Context, alg MatchingAlgorithm, player *Player, candidates []*Player, minQuality float64) []scoredCandidate {
+	var scores []scoredCandidate
+	for _, candidate := range candidates {
+		if candidate.ID == player.ID {
+			// 跳过自己
+			continue
+		}
+
+		score, err := alg.CalculateMatchScore(ctx, player, candidate)
+		if err != nil {
+			continue //跳过计算错误
+		}
+
+		// 只考虑超过最低质量阈值的匹配
+		if score >= minQuality {
+			scores = append(scores, scoredCandidate{player: candidate, score: score})
+		}
+	}
+	return scores
+}
+
+// NewMatchID 生成对局ID：前缀后依次为秒级时间戳和随机后缀，
+// 同一玩家在同一秒内重新组局也不会得到相同的ID
+func NewMatchID(prefix string) string {
+	return fmt.Sprintf("%s_%d_%s", prefix, time.Now().Unix(), uuid.NewString()[:8])
+}
+
+// 记录一次成功匹配的质量
+func recordMatchQuality(stats *AlgorithmStats, quality float64) {
+	stats.TotalMatches++
+	stats.SuccessfulMatches++
+
+	//更新平均质量
+	stats.AverageMatchQuality = (stats.AverageMatchQuality*float64(stats.TotalMatches-1) + quality) / float64(stats.TotalMatches)
+	// 更新质量分布
+	qualityBucket := fmt.Sprintf("%.1f-%.1f", math.Floor(quality*10)/10, math.Ceil(quality*10)/10)
+	stats.QualityDistribution[qualityBucket]++
+	stats.LastUpdated = time.Now()
+}
+
+func newAlgorithmStats() *AlgorithmStats {
+	return &AlgorithmStats{
+		QualityDistribution: make(map[string]int64),
+		LastUpdated:         time.Now(),
+	}
+}
+
+// 统计快照，避免调用方在锁外读取时与匹配协程并发写入冲突
+func (s *AlgorithmStats) snapshot() *AlgorithmStats {
+	copied := *s
+	copied.QualityDistribution = make(map[string]int64, len(s.QualityDistribution))
+	for bucket, count := range s.QualityDistribution {
+		copied.QualityDistribution[bucket] = count
+	}
+	return &copied
+}
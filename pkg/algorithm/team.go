@@ -0,0 +1,317 @@
+package algorithm
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// 队伍标识，与 room_players.team / match_players.team_assignment 一致
+const (
+	TeamA = "team_a"
+	TeamB = "team_b"
+)
+
+// 一次匹配中的一支队伍
+// 分角色时 Players 按角色顺序排列，Roles[i] 为 Players[i] 担任的角色
+type Team struct {
+	Name       string    `json:"name"`
+	Players    []*Player `json:"players"`
+	Roles      []string  `json:"roles,omitempty"`
+	AverageMMR float64   `json:"average_mmr"`
+}
+
+// 穷举分队的最大单元数，game_rooms.max_players 最多为10
+const maxBalanceUnits = 20
+
+// BalanceTeams 将 2*teamSize 名玩家分成两队，使两队平均MMR差最小
+// 同一预组队的玩家作为一个单元整体分配；第一个单元固定在A队，
+// 穷举其余单元的组合（5v5 全单排时仅需126种）
+func BalanceTeams(players []*Player, teamSize int) ([]*Team, error) {
+	return BalanceRoleTeams(players, TeamFormat{Size: teamSize})
+}
+
+// BalanceRoleTeams 按对局格式分队；格式包含角色时，每队的每个角色恰好一人，
+// 只考虑两队都能完成角色分配的分法，MMR差使用各自角色的MMR，差值相同时偏好代价小者优先
+func BalanceRoleTeams(players []*Player, format TeamFormat) ([]*Team, error) {
+	teamSize := format.Size
+	if len(format.Roles) > 0 && len(format.Roles) != teamSize {
+		return nil, fmt.Errorf("team size %d does not match %d roles", teamSize, len(format.Roles))
+	}
+	if teamSize <= 0 || len(players) != 2*teamSize {
+		return nil, fmt.Errorf("cannot split %d players into 2 teams of %d", len(players), teamSize)
+	}
+	units := groupUnits(players)
+	n := len(units)
+	if n > maxBalanceUnits {
+		return nil, fmt.Errorf("too many units to balance: %d", n)
+	}
+
+	sizes := make([]int, n)
+	sums := make([]float64, n)
+	var total float64
+	for i, unit := range units {
+		sizes[i] = len(unit)
+		for _, p := range unit {
+			sums[i] += p.EffectiveMMR()
+		}
+		total += sums[i]
+	}
+
+	split := func(mask int) (*Team, *Team) {
+		teamA := &Team{Name: TeamA, Players: append([]*Player{}, units[0]...)}
+		teamB := &Team{Name: TeamB}
+		for i := 0; i < n-1; i++ {
+			if mask&(1<<i) != 0 {
+				teamA.Players = append(teamA.Players, units[i+1]...)
+			} else {
+				teamB.Players = append(teamB.Players, units[i+1]...)
+			}
+		}
+		return teamA, teamB
+	}
+
+	var best []*Team
+	bestMask, bestGap, bestCost := -1, math.Inf(1), math.MaxInt
+	for mask := 0; mask < 1<<(n-1); mask++ {
+		// 位i表示 units[i+1] 是否在A队
+		sizeA, sumA := sizes[0], sums[0]
+		for i := 0; i < n-1; i++ {
+			if mask&(1<<i) != 0 {
+				sizeA += sizes[i+1]
+				sumA += sums[i+1]
+			}
+		}
+		if sizeA != teamSize {
+			continue
+		}
+		if len(format.Roles) == 0 {
+			if gap := math.Abs(2*sumA - total); gap < bestGap {
+				bestMask, bestGap = mask, gap
+			}
+			continue
+		}
+
+		teamA, teamB := split(mask)
+		rolesA, costA, okA := assignRoles(teamA.Players, format.Roles)
+		if !okA {
+			continue
+		}
+		rolesB, costB, okB := assignRoles(teamB.Players, format.Roles)
+		if !okB {
+			continue
+		}
+		teamA.Roles, teamB.Roles = rolesA, rolesB
+		teamA.AverageMMR, teamB.AverageMMR = averageRoleMMR(teamA), averageRoleMMR(teamB)
+		gap, cost := math.Abs(teamA.AverageMMR-teamB.AverageMMR), costA+costB
+		if gap < bestGap || (gap == bestGap && cost < bestCost) {
+			bestGap, bestCost = gap, cost
+			best = []*Team{teamA, teamB}
+		}
+	}
+	if len(format.Roles) == 0 {
+		if bestMask < 0 {
+			return nil, fmt.Errorf("cannot keep parties together in teams of %d", teamSize)
+		}
+		teamA, teamB := split(bestMask)
+		teamA.AverageMMR = averageEffectiveMMR(teamA.Players)
+		teamB.AverageMMR = averageEffectiveMMR(teamB.Players)
+		return []*Team{teamA, teamB}, nil
+	}
+	if best == nil {
+		return nil, fmt.Errorf("cannot fill roles %v in teams of %d", format.Roles, teamSize)
+	}
+	for _, team := range best {
+		sortByRole(team, format.Roles)
+	}
+	return best, nil
+}
+
+// 组建 teamSize v teamSize 的对局：玩家所在的预组队整体入选，
+// 其余单元按与玩家的平均匹配得分从高到低填入剩余位置，再按MMR均衡分队。
+// 质量为所有入选玩家与玩家匹配得分的平均值。
+// 分角色时入选的玩家必须能填满两队的所有角色；满员的对局不满足 format.Feasible 时换下一个单元。
+func findTeamMatch(ctx context.Context, alg MatchingAlgorithm, player *Player, candidates []*Player, format TeamFormat) (*MatchResult, error) {
+	startTime := time.Now()
+	teamSize := format.Size
+	if teamSize <= 0 {
+		return nil, fmt.Errorf("invalid team size: %d", teamSize)
+	}
+	lobbySize := 2 * teamSize
+	if len(candidates) < lobbySize-1 {
+		return nil, fmt.Errorf("not enough candidates: need %d, got %d", lobbySize-1, len(candidates))
+	}
+
+	// 玩家自己的单元
+	lobby := []*Player{player}
+	var others []*Player
+	for _, candidate := range candidates {
+		if candidate.ID == player.ID {
+			continue
+		}
+		if player.PartyID != "" && candidate.PartyID == player.PartyID {
+			lobby = append(lobby, candidate)
+			continue
+		}
+		others = append(others, candidate)
+	}
+	if len(lobby) > teamSize {
+		return nil, fmt.Errorf("party size %d exceeds team size %d", len(lobby), teamSize)
+	}
+	if !isCompleteUnit(lobby) {
+		return nil, fmt.Errorf("party %s is incomplete in candidates", player.PartyID)
+	}
+	if !rolesFeasible(lobby, format.Roles) {
+		return nil, fmt.Errorf("party %s cannot cover distinct roles", player.PartyID)
+	}
+
+	minQuality := alg.GetConfig().Thresholds["min_quality"]
+	type scoredUnit struct {
+		players []*Player
+		score   float64
+	}
+	var units []scoredUnit
+	for _, unit := range groupUnits(others) {
+		if len(unit) > teamSize || !isCompleteUnit(unit) {
+			continue
+		}
+		var total float64
+		qualified := true
+		for _, p := range unit {
+			score, err := alg.CalculateMatchScore(ctx, player, p)
+			if err != nil {
+				qualified = false
+				break
+			}
+			total += score
+		}
+		if qualified && total/float64(len(unit)) >= minQuality {
+			units = append(units, scoredUnit{players: unit, score: total / float64(len(unit))})
+		}
+	}
+	sort.SliceStable(units, func(i, j int) bool {
+		return units[i].score > units[j].score
+	})
+
+	// 贪心填充：单元需放得下，且满员时必须能保证组队不被拆散
+	var quality float64
+	var teams []*Team
+	for _, unit := range units {
+		if len(lobby)+len(unit.players) > lobbySize {
+			continue
+		}
+		next := append(append([]*Player{}, lobby...), unit.players...)
+		if !rolesFeasible(next, format.Roles) {
+			continue
+		}
+		if len(next) == lobbySize {
+			balanced, err := BalanceRoleTeams(next, format)
+			if err != nil {
+				continue
+			}
+			if format.Feasible != nil && !format.Feasible(next) {
+				continue
+			}
+			teams = balanced
+		}
+		lobby = next
+		quality += unit.score * float64(len(unit.players))
+		if teams != nil {
+			break
+		}
+	}
+	if teams == nil {
+		return nil, fmt.Errorf("no suitable matches found: lobby %d/%d", len(lobby), lobbySize)
+	}
+	// 玩家自身及队友不计入质量
+	quality /= float64(lobbySize - countParty(lobby, player))
+
+	metadata := map[string]interface{}{
+		"candidates_count": len(candidates),
+		"qualified_count":  len(units),
+		"team_size":        teamSize,
+		"roles":            len(format.Roles) > 0,
+		"mmr_gap":          math.Abs(teams[0].AverageMMR - teams[1].AverageMMR),
+	}
+	if teamAlg, ok := alg.(TeamRatingAlgorithm); ok {
+		if teamQuality, err := teamAlg.CalculateTeamMatchQuality(ctx, [][]*Player{teams[0].Players, teams[1].Players}); err == nil {
+			metadata["team_quality"] = teamQuality
+		}
+	}
+	metadata["calculation_time"] = time.Since(startTime)
+
+	return &MatchResult{
+		MatchID:   NewMatchID(fmt.Sprintf("match_%d_%dv%d", player.ID, teamSize, teamSize)),
+		Players:   lobby,
+		Teams:     teams,
+		Quality:   quality,
+		Algorithm: alg.Name(),
+		Metadata:  metadata,
+	}, nil
+}
+
+// 组队单元的成员是否全部在候选列表中
+func isCompleteUnit(unit []*Player) bool {
+	return unit[0].PartyID == "" || len(unit) == unit[0].PartySize
+}
+
+// 对局中与玩家同一单元的人数（含玩家自身）
+func countParty(lobby []*Player, player *Player) int {
+	if player.PartyID == "" {
+		return 1
+	}
+	count := 0
+	for _, p := range lobby {
+		if p.PartyID == player.PartyID {
+			count++
+		}
+	}
+	return count
+}
+
+func averageMMR(players []*Player) float64 {
+	if len(players) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, p := range players {
+		sum += p.MMR
+	}
+	return sum / float64(len(players))
+}
+
+// 按角色MMR计算的队伍平均MMR
+func averageRoleMMR(team *Team) float64 {
+	var sum float64
+	for i, p := range team.Players {
+		sum += p.MMRFor(team.Roles[i])
+	}
+	return sum / float64(len(team.Players))
+}
+
+// 按格式中的角色顺序排列队伍成员，使位置 i+1 对应第 i 个角色
+func sortByRole(team *Team, roles []string) {
+	players := make([]*Player, len(roles))
+	for i, role := range team.Roles {
+		for j, r := range roles {
+			if r == role {
+				players[j] = team.Players[i]
+			}
+		}
+	}
+	team.Players = players
+	team.Roles = append([]string{}, roles...)
+}
+
+func averageEffectiveMMR(players []*Player) float64 {
+	if len(players) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, p := range players {
+		sum += p.EffectiveMMR()
+	}
+	return sum / float64(len(players))
+}
@@ -0,0 +1,454 @@
+package algorithm
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/mangooer/gamehub-arena/internal/config"
+)
+
+// 单局更新后方差最多收缩到原来的比例下限，避免数值误差导致sigma为0
+const trueSkillMinVarianceFactor = 1e-6
+
+// TrueSkillAlgorithm 微软TrueSkill评级算法
+// 每个玩家的技能用正态分布 N(mu, sigma²) 表示，队伍技能为队员技能之和，
+// 适用于5v5等团队模式。Player.MMR 与 mu 之间按 mmr_scale 线性换算。
+type TrueSkillAlgorithm struct {
+	config *config.AlgorithmConfig
+	stats  *AlgorithmStats
+	mu     sync.RWMutex
+}
+
+func NewTrueSkillAlgorithm(config *config.AlgorithmConfig) *TrueSkillAlgorithm {
+	return &TrueSkillAlgorithm{
+		config: config,
+		stats:  newAlgorithmStats(),
+	}
+}
+
+func (t *TrueSkillAlgorithm) Name() string {
+	return t.config.Name
+}
+
+func (t *TrueSkillAlgorithm) Version() string {
+	return t.config.Version
+}
+
+func (t *TrueSkillAlgorithm) Description() string {
+	return t.config.Description
+}
+
+func (t *TrueSkillAlgorithm) SetConfig(config *config.AlgorithmConfig) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.config = config
+	return nil
+}
+
+func (t *TrueSkillAlgorithm) GetConfig() *config.AlgorithmConfig {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.config
+}
+
+// CalculateMatchScore 1v1 匹配得分，MMR因子使用双方的平局概率
+func (t *TrueSkillAlgorithm) CalculateMatchScore(ctx context.Context, p1, p2 *Player) (float64, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if err := t.ValidatePlayer(p1); err != nil {
+		return 0, fmt.Errorf("player 1 validation failed: %w", err)
+	}
+	if err := t.ValidatePlayer(p2); err != nil {
+		return 0, fmt.Errorf("player 2 validation failed: %w", err)
+	}
+
+	drawScore := t.drawProbability([]*Player{p1}, []*Player{p2})
+	totalScore := weightedScore(t.config, levelScore(t.config, p1, p2), winRateScore(t.config, p1, p2), pingScore(t.config, p1, p2), drawScore)
+
+	return math.Max(0, math.Min(1, totalScore)), nil
+}
+
+func (t *TrueSkillAlgorithm) FindOptimalMatch(ctx context.Context, player *Player, candidates []*Player) (*MatchResult, error) {
+	startTime := time.Now()
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no candidates found")
+	}
+
+	scores := qualifiedCandidates(ctx, t, player, candidates, t.GetConfig().Thresholds["min_quality"])
+	if len(scores) == 0 {
+		return nil, fmt.Errorf("no suitable matches found")
+	}
+
+	bestMatch := scores[0]
+	for _, candidate := range scores[1:] {
+		if candidate.score > bestMatch.score {
+			bestMatch = candidate
+		}
+	}
+
+	t.updateStats(bestMatch.score)
+
+	return &MatchResult{
+		MatchID:    NewMatchID(fmt.Sprintf("match_%d_%d", player.ID, bestMatch.player.ID)),
+		Players:    []*Player{player, bestMatch.player},
+		Quality:    bestMatch.score,
+		Confidence: t.calculateConfidence([]*Player{player, bestMatch.player}),
+		Algorithm:  t.Name(),
+		Metadata: map[string]interface{}{
+			"calculation_time": time.Since(startTime),
+			"candidates_count": len(candidates),
+			"qualified_count":  len(scores),
+		},
+	}, nil
+}
+
+// FindTeamMatch 按对局格式为玩家组建两队对局
+func (t *TrueSkillAlgorithm) FindTeamMatch(ctx context.Context, player *Player, candidates []*Player, format TeamFormat) (*MatchResult, error) {
+	result, err := findTeamMatch(ctx, t, player, candidates, format)
+	if err != nil {
+		return nil, err
+	}
+	result.Confidence = t.calculateConfidence(result.Players)
+	t.updateStats(result.Quality)
+	return result, nil
+}
+
+// FindBatchMatches 在整批排队玩家中全局优化组局，见 findBatchMatches
+func (t *TrueSkillAlgorithm) FindBatchMatches(ctx context.Context, players []*Player, options BatchOptions) ([]*MatchResult, error) {
+	results, err := findBatchMatches(ctx, t, players, options)
+	if err != nil {
+		return nil, err
+	}
+	for _, result := range results {
+		result.Confidence = t.calculateConfidence(result.Players)
+		t.updateStats(result.Quality)
+	}
+	return results, nil
+}
+
+// CalculateMMR 1v1 对局结果更新，对手技能由 OpponentMMR/OpponentRD 换算
+func (t *TrueSkillAlgorithm) CalculateMMR(ctx context.Context, player *Player, gameResult *GameResult) (float64, error) {
+	if err := t.ValidatePlayer(player); err != nil {
+		return 0, err
+	}
+	if gameResult == nil {
+		return 0, fmt.Errorf("game result cannot be nil")
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	scale := t.mmrScale()
+	opponent := &Player{Mu: gameResult.OpponentMMR / scale, Sigma: gameResult.OpponentRD / scale}
+	ranks := []int{1, 0}
+	if gameResult.IsWin {
+		ranks = []int{0, 1}
+	}
+	if err := t.updateTeams([][]*Player{{player}, {opponent}}, ranks); err != nil {
+		return 0, err
+	}
+	return player.MMR, nil
+}
+
+// CalculateTeamMatchQuality 两队对阵的平局概率，越接近1说明双方越势均力敌
+func (t *TrueSkillAlgorithm) CalculateTeamMatchQuality(ctx context.Context, teams [][]*Player) (float64, error) {
+	if err := t.validateTeams(teams); err != nil {
+		return 0, err
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.drawProbability(teams[0], teams[1]), nil
+}
+
+// UpdateTeamRatings 根据两队名次更新每名玩家的 Mu、Sigma 以及对应的 MMR
+func (t *TrueSkillAlgorithm) UpdateTeamRatings(ctx context.Context, teams [][]*Player, ranks []int) error {
+	if err := t.validateTeams(teams); err != nil {
+		return err
+	}
+	if len(ranks) != len(teams) {
+		return fmt.Errorf("ranks count %d does not match teams count %d", len(ranks), len(teams))
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.updateTeams(teams, ranks)
+}
+
+// PredictOutcome 队伍技能为队员技能之和，获胜概率为 Φ((μA-μB)/c)，不计平局；
+// MMR变化为在队员副本上分别按胜、负执行一次评级更新的结果
+func (t *TrueSkillAlgorithm) PredictOutcome(ctx context.Context, teams []*Team) (*MatchPrediction, error) {
+	if err := validatePredictionTeams(teams); err != nil {
+		return nil, err
+	}
+	players := [][]*Player{teams[0].Players, teams[1].Players}
+	if err := t.validateTeams(players); err != nil {
+		return nil, err
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	beta := floatParam(t.config, "beta", 25.0/6)
+	var muA, muB, sigmaSqSum float64
+	for _, p := range players[0] {
+		muA += t.muOf(p)
+		sigmaSqSum += t.sigmaOf(p) * t.sigmaOf(p)
+	}
+	for _, p := range players[1] {
+		muB += t.muOf(p)
+		sigmaSqSum += t.sigmaOf(p) * t.sigmaOf(p)
+	}
+	n := float64(len(players[0]) + len(players[1]))
+	winA := normCDF((muA - muB) / math.Sqrt(n*beta*beta+sigmaSqSum))
+
+	// 按第一队胜、第二队胜各模拟一次更新
+	scale := t.mmrScale()
+	gains := make(map[uint64]float64)
+	losses := make(map[uint64]float64)
+	for winner := range players {
+		copies := make([][]*Player, len(players))
+		for i, team := range players {
+			for _, p := range team {
+				c := *p
+				copies[i] = append(copies[i], &c)
+			}
+		}
+		ranks := []int{1, 1}
+		ranks[winner] = 0
+		if err := t.updateTeams(copies, ranks); err != nil {
+			return nil, err
+		}
+		for i, team := range players {
+			for j, p := range team {
+				delta := (copies[i][j].Mu - t.muOf(p)) * scale
+				if i == winner {
+					gains[p.ID] = delta
+				} else {
+					losses[p.ID] = delta
+				}
+			}
+		}
+	}
+
+	return newMatchPrediction(t.config.Name, teams, winA, func(player *Player, _ []*Player) (float64, float64) {
+		return gains[player.ID], losses[player.ID]
+	}), nil
+}
+
+func (t *TrueSkillAlgorithm) ValidatePlayer(player *Player) error {
+	return validatePlayer(player)
+}
+
+func (t *TrueSkillAlgorithm) validateTeams(teams [][]*Player) error {
+	if len(teams) != 2 {
+		return fmt.Errorf("trueskill supports exactly 2 teams, got %d", len(teams))
+	}
+	for i, team := range teams {
+		if len(team) == 0 {
+			return fmt.Errorf("team %d is empty", i)
+		}
+		for _, player := range team {
+			if err := t.ValidatePlayer(player); err != nil {
+				return fmt.Errorf("team %d validation failed: %w", i, err)
+			}
+		}
+	}
+	return nil
+}
+
+// 两队情况下因子图的解析解
+func (t *TrueSkillAlgorithm) updateTeams(teams [][]*Player, ranks []int) error {
+	beta := floatParam(t.config, "beta", 25.0/6)
+	tau := floatParam(t.config, "tau", 25.0/300)
+
+	winner, loser := teams[0], teams[1]
+	if ranks[1] < ranks[0] {
+		winner, loser = teams[1], teams[0]
+	}
+	isDraw := ranks[0] == ranks[1]
+
+	// 动态因子：每局前为sigma加入tau，防止sigma无限收敛
+	type prior struct{ mu, sigmaSq float64 }
+	priors := make(map[*Player]prior)
+	var winnerMu, loserMu, sigmaSqSum float64
+	for _, p := range winner {
+		s := t.sigmaOf(p)
+		priors[p] = prior{t.muOf(p), s*s + tau*tau}
+		winnerMu += priors[p].mu
+		sigmaSqSum += priors[p].sigmaSq
+	}
+	for _, p := range loser {
+		s := t.sigmaOf(p)
+		priors[p] = prior{t.muOf(p), s*s + tau*tau}
+		loserMu += priors[p].mu
+		sigmaSqSum += priors[p].sigmaSq
+	}
+
+	n := float64(len(winner) + len(loser))
+	c := math.Sqrt(sigmaSqSum + n*beta*beta)
+	diff := (winnerMu - loserMu) / c
+	margin := t.drawMargin(n, beta) / c
+
+	var v, w float64
+	if isDraw {
+		v, w = vDraw(diff, margin), wDraw(diff, margin)
+	} else {
+		v, w = vWin(diff, margin), wWin(diff, margin)
+	}
+	if math.IsNaN(v) || math.IsNaN(w) {
+		return fmt.Errorf("trueskill update diverged: diff=%f margin=%f", diff, margin)
+	}
+
+	scale := t.mmrScale()
+	apply := func(team []*Player, sign float64) {
+		for _, p := range team {
+			pr := priors[p]
+			p.Mu = pr.mu + sign*pr.sigmaSq/c*v
+			p.Sigma = math.Sqrt(pr.sigmaSq * math.Max(1-pr.sigmaSq/(c*c)*w, trueSkillMinVarianceFactor))
+			p.MMR = p.Mu * scale
+		}
+	}
+	apply(winner, 1)
+	apply(loser, -1)
+	return nil
+}
+
+// 两队平局概率（TrueSkill匹配质量）
+func (t *TrueSkillAlgorithm) drawProbability(teamA, teamB []*Player) float64 {
+	beta := floatParam(t.config, "beta", 25.0/6)
+
+	var muA, muB, sigmaSqSum float64
+	for _, p := range teamA {
+		muA += t.muOf(p)
+		sigmaSqSum += t.sigmaOf(p) * t.sigmaOf(p)
+	}
+	for _, p := range teamB {
+		muB += t.muOf(p)
+		sigmaSqSum += t.sigmaOf(p) * t.sigmaOf(p)
+	}
+
+	n := float64(len(teamA) + len(teamB))
+	denominator := n*beta*beta + sigmaSqSum
+	return math.Sqrt(n*beta*beta/denominator) * math.Exp(-(muA-muB)*(muA-muB)/(2*denominator))
+}
+
+// 由配置的平局概率反推平局边界
+func (t *TrueSkillAlgorithm) drawMargin(n, beta float64) float64 {
+	drawProbability := floatParam(t.config, "draw_probability", 0.1)
+	return normInvCDF((drawProbability+1)/2) * math.Sqrt(n) * beta
+}
+
+// 置信度：所有玩家sigma相对初始sigma的收敛程度，取最不确定的玩家
+func (t *TrueSkillAlgorithm) calculateConfidence(players []*Player) float64 {
+	initialSigma := floatParam(t.config, "sigma", 25.0/3)
+	confidence := 1.0
+	for _, p := range players {
+		confidence = math.Min(confidence, 1.0-t.sigmaOf(p)/initialSigma)
+	}
+	return math.Max(0, confidence)
+}
+
+func (t *TrueSkillAlgorithm) muOf(p *Player) float64 {
+	if p.Mu > 0 {
+		return p.Mu
+	}
+	if p.MMR > 0 {
+		return p.MMR / t.mmrScale()
+	}
+	return floatParam(t.config, "mu", 25.0)
+}
+
+func (t *TrueSkillAlgorithm) sigmaOf(p *Player) float64 {
+	if p.Sigma > 0 {
+		return p.Sigma
+	}
+	return floatParam(t.config, "sigma", 25.0/3)
+}
+
+func (t *TrueSkillAlgorithm) mmrScale() float64 {
+	return floatParam(t.config, "mmr_scale", 48)
+}
+
+func (t *TrueSkillAlgorithm) updateStats(quality float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	recordMatchQuality(t.stats, quality)
+}
+
+func (t *TrueSkillAlgorithm) GetStats() *AlgorithmStats {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.stats.snapshot()
+}
+
+func (t *TrueSkillAlgorithm) ResetStats() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.stats = newAlgorithmStats()
+}
+
+// 标准正态分布函数
+
+func normPDF(x float64) float64 {
+	return math.Exp(-x*x/2) / math.Sqrt(2*math.Pi)
+}
+
+func normCDF(x float64) float64 {
+	return 0.5 * math.Erfc(-x/math.Sqrt2)
+}
+
+func normInvCDF(p float64) float64 {
+	return math.Sqrt2 * math.Erfinv(2*p-1)
+}
+
+// TrueSkill 截断高斯修正函数
+
+func vWin(diff, margin float64) float64 {
+	x := diff - margin
+	denominator := normCDF(x)
+	if denominator < 1e-300 {
+		return -x
+	}
+	return normPDF(x) / denominator
+}
+
+func wWin(diff, margin float64) float64 {
+	x := diff - margin
+	v := vWin(diff, margin)
+	return v * (v + x)
+}
+
+func vDraw(diff, margin float64) float64 {
+	absDiff := math.Abs(diff)
+	a, b := margin-absDiff, -margin-absDiff
+	denominator := normCDF(a) - normCDF(b)
+	v := a
+	if denominator > 1e-300 {
+		v = (normPDF(b) - normPDF(a)) / denominator
+	}
+	if diff < 0 {
+		return -v
+	}
+	return v
+}
+
+func wDraw(diff, margin float64) float64 {
+	absDiff := math.Abs(diff)
+	a, b := margin-absDiff, -margin-absDiff
+	denominator := normCDF(a) - normCDF(b)
+	if denominator < 1e-300 {
+		return 1
+	}
+	v := vDraw(absDiff, margin)
+	return v*v + (a*normPDF(a)-b*normPDF(b))/denominator
+}
@@ -0,0 +1,297 @@
+package match
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/mangooer/gamehub-arena/internal/cache"
+	"github.com/mangooer/gamehub-arena/internal/config"
+	"github.com/mangooer/gamehub-arena/internal/logger"
+	"github.com/mangooer/gamehub-arena/pkg/algorithm"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrInvalidBackfillSlot = errors.New("invalid backfill slot")
+	ErrBackfillSlotTaken   = errors.New("backfill slot already taken")
+)
+
+// 原子地取走补位空位，只有一个调用会成功
+// KEYS: 空位哈希
+// ARGV: 空位ID
+const takeBackfillSlotScript = `
+local slot = redis.call('HGET', KEYS[1], ARGV[1])
+if not slot then
+	return ''
+end
+redis.call('HDEL', KEYS[1], ARGV[1])
+return slot
+`
+
+// 进行中房间的一个空位，由房间在玩家离开后发布
+type BackfillSlot struct {
+	ID         string    `json:"id"`
+	RoomID     uint64    `json:"room_id"`
+	GameMode   string    `json:"game_mode"`
+	Region     string    `json:"region"`
+	DataCenter string    `json:"data_center,omitempty"`
+	Team       string    `json:"team"`
+	Role       string    `json:"role,omitempty"`
+	Position   int       `json:"position"`
+	PostedAt   time.Time `json:"posted_at"`
+
+	// 空位所在队伍的平均水平，用于与候选玩家计算匹配得分
+	TeamAverageMMR   float64 `json:"team_average_mmr"`
+	TeamAverageLevel int     `json:"team_average_level"`
+	TeamWinRate      float64 `json:"team_win_rate"`
+}
+
+// 代表空位所在队伍的虚拟玩家，与候选玩家使用 CalculateMatchScore 评分
+// 进行中对局的队伍评级已确定，虚拟玩家的场次为 games，避免被当作定级期玩家
+func (s *BackfillSlot) anchor(games int) *algorithm.Player {
+	level := s.TeamAverageLevel
+	if level < 1 {
+		level = 1
+	}
+	anchor := &algorithm.Player{
+		ID:        s.RoomID,
+		Level:     level,
+		WinRate:   s.TeamWinRate,
+		GameMode:  s.GameMode,
+		Region:    s.Region,
+		MMR:       s.TeamAverageMMR,
+		QueueTime: s.PostedAt,
+	}
+	anchor.WinCount = int(math.Round(float64(games) * math.Max(0, math.Min(1, s.TeamWinRate))))
+	anchor.LoseCount = games - anchor.WinCount
+	if s.DataCenter != "" {
+		// 房间机房已确定，候选玩家的延迟即到该机房的延迟
+		anchor.Pings = map[string]int{s.DataCenter: 0}
+	}
+	return anchor
+}
+
+// 空位所属的匹配桶：空位所在区域中包含队伍平均MMR的第一个MMR段，超出所有段时取最近的段
+// 多实例部署时由持有该桶租约的实例负责补位
+func (s *BackfillSlot) bucket() MatchBucket {
+	rank := mmrRanks[len(mmrRanks)-1]
+	for _, r := range mmrRanks {
+		if s.TeamAverageMMR < r.MaxMMR {
+			rank = r
+			break
+		}
+	}
+	return MatchBucket{GameMode: s.GameMode, Region: s.Region, Rank: rank}
+}
+
+// 空位是否接受该玩家：玩家已选择补位、不在低优先级队列、单人排队、能担任空位角色，
+// 队伍平均MMR和到房间机房的延迟在玩家当前的搜索窗口内
+func (s *BackfillSlot) accepts(player *algorithm.Player, window SearchWindow) bool {
+	if !player.AllowBackfill || player.LowPriority || player.PartyID != "" || player.GameMode != s.GameMode {
+		return false
+	}
+	if s.Role != "" {
+		if _, ok := player.RoleCost(s.Role); !ok {
+			return false
+		}
+	}
+	if math.Abs(player.EffectiveMMR()-s.TeamAverageMMR) > window.MMRDelta {
+		return false
+	}
+	if s.DataCenter != "" && window.MaxPing > 0 && player.PingTo(s.DataCenter) > window.MaxPing {
+		return false
+	}
+	return true
+}
+
+// 将补位玩家交给房间
+type BackfillHandler interface {
+	FillBackfillSlot(ctx context.Context, slot *BackfillSlot, player *algorithm.Player) error
+}
+
+// BackfillManager 进行中房间的补位队列
+//
+// 空位保存在 match:backfill:{mode}，空位ID -> BackfillSlot JSON。
+// 引擎优先从普通队列中为空位挑选选择了补位的玩家，评分方式与 CalculateMatchScore 一致。
+type BackfillManager struct {
+	cache   cache.CacheService
+	queue   *QueueManager
+	handler BackfillHandler
+	config  *config.MatchConfig
+	logger  logger.Logger
+}
+
+func NewBackfillManager(cache cache.CacheService, queue *QueueManager, handler BackfillHandler, config *config.MatchConfig, logger logger.Logger) *BackfillManager {
+	return &BackfillManager{
+		cache:   cache,
+		queue:   queue,
+		handler: handler,
+		config:  config,
+		logger:  logger,
+	}
+}
+
+// PostSlot 发布空位，同一房间同一位置重复发布会覆盖原空位
+func (b *BackfillManager) PostSlot(ctx context.Context, slot *BackfillSlot) error {
+	if slot == nil || slot.RoomID == 0 || slot.Team == "" {
+		return fmt.Errorf("%w: room and team are required", ErrInvalidBackfillSlot)
+	}
+	if !b.queue.isValidGameMode(slot.GameMode) {
+		return fmt.Errorf("%w: %s", ErrInvalidGameMode, slot.GameMode)
+	}
+	if slot.Region == "" {
+		slot.Region = b.config.Queue.DefaultRegion
+	}
+	if roles := b.config.Roles(slot.GameMode); len(roles) > 0 && !slices.Contains(roles, slot.Role) {
+		return fmt.Errorf("%w: role %q not in %s", ErrInvalidBackfillSlot, slot.Role, slot.GameMode)
+	}
+	slot.ID = fmt.Sprintf("%d:%s:%d", slot.RoomID, slot.Team, slot.Position)
+	if slot.PostedAt.IsZero() {
+		slot.PostedAt = time.Now()
+	}
+
+	data, err := json.Marshal(slot)
+	if err != nil {
+		return fmt.Errorf("failed to marshal backfill slot: %w", err)
+	}
+	if err := b.cache.HSet(ctx, cache.MatchBackfillKey(slot.GameMode), slot.ID, data); err != nil {
+		return fmt.Errorf("failed to post backfill slot: %w", err)
+	}
+
+	b.logger.GetLogger().Info("Backfill slot posted",
+		zap.String("slot_id", slot.ID),
+		zap.Uint64("room_id", slot.RoomID),
+		zap.String("team", slot.Team),
+		zap.String("role", slot.Role),
+		zap.Float64("team_average_mmr", slot.TeamAverageMMR),
+	)
+	return nil
+}
+
+// CancelSlot 撤回空位（房间结束或空位已由其他方式补上）
+func (b *BackfillManager) CancelSlot(ctx context.Context, gameMode, slotID string) error {
+	if err := b.cache.HDel(ctx, cache.MatchBackfillKey(gameMode), slotID); err != nil {
+		return fmt.Errorf("failed to cancel backfill slot: %w", err)
+	}
+	return nil
+}
+
+// OpenSlots 获取模式下所有空位，按发布时间从早到晚排列
+func (b *BackfillManager) OpenSlots(ctx context.Context, gameMode string) ([]*BackfillSlot, error) {
+	values, err := b.cache.HGetAll(ctx, cache.MatchBackfillKey(gameMode))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get backfill slots: %w", err)
+	}
+
+	slots := make([]*BackfillSlot, 0, len(values))
+	for id, data := range values {
+		var slot BackfillSlot
+		if err := json.Unmarshal([]byte(data), &slot); err != nil {
+			b.logger.GetLogger().Warn("invalid backfill slot",
+				zap.String("slot_id", id),
+				zap.Error(err),
+			)
+			continue
+		}
+		slots = append(slots, &slot)
+	}
+	sort.Slice(slots, func(i, j int) bool {
+		return slots[i].PostedAt.Before(slots[j].PostedAt)
+	})
+	return slots, nil
+}
+
+// FillSlot 为空位挑选得分最高的候选玩家并交给房间，没有合适玩家时返回 nil
+func (b *BackfillManager) FillSlot(ctx context.Context, matcher algorithm.MatchingAlgorithm, slot *BackfillSlot) (*algorithm.Player, error) {
+	candidates, err := b.queue.GetBackfillCandidates(ctx, slot)
+	if err != nil {
+		return nil, err
+	}
+
+	anchor := slot.anchor(algorithm.PlacementGames(matcher.GetConfig()))
+	minQuality := matcher.GetConfig().Thresholds["min_quality"]
+	var best *algorithm.Player
+	bestScore := -1.0
+	for _, candidate := range candidates {
+		score, err := matcher.CalculateMatchScore(ctx, anchor, candidate)
+		if err != nil || score < minQuality {
+			continue
+		}
+		if score > bestScore {
+			best, bestScore = candidate, score
+		}
+	}
+	if best == nil {
+		return nil, nil
+	}
+
+	if err := b.takeSlot(ctx, slot); err != nil {
+		return nil, err
+	}
+	result := &algorithm.MatchResult{
+		MatchID:   algorithm.NewMatchID(fmt.Sprintf("backfill_%d_%d", slot.RoomID, best.ID)),
+		Players:   []*algorithm.Player{best},
+		Teams:     []*algorithm.Team{{Name: slot.Team, Players: []*algorithm.Player{best}, AverageMMR: slot.TeamAverageMMR}},
+		Quality:   bestScore,
+		Algorithm: matcher.Name(),
+		CreatedAt: time.Now(),
+	}
+	if slot.Role != "" {
+		result.Teams[0].Roles = []string{slot.Role}
+	}
+	entries, err := b.queue.ClaimMatch(ctx, result)
+	if err != nil {
+		b.restoreSlot(ctx, slot)
+		return nil, err
+	}
+
+	if err := b.handler.FillBackfillSlot(ctx, slot, best); err != nil {
+		b.restoreSlot(ctx, slot)
+		for _, entry := range entries {
+			if requeueErr := b.queue.Requeue(ctx, entry); requeueErr != nil {
+				b.logger.GetLogger().Error("failed to requeue backfill player",
+					zap.Uint64("user_id", best.ID),
+					zap.Error(requeueErr),
+				)
+			}
+		}
+		return nil, fmt.Errorf("failed to fill backfill slot: %w", err)
+	}
+	b.queue.RecordMatch(ctx, result)
+
+	b.logger.GetLogger().Info("Backfill slot filled",
+		zap.String("slot_id", slot.ID),
+		zap.Uint64("room_id", slot.RoomID),
+		zap.Uint64("user_id", best.ID),
+		zap.Float64("score", bestScore),
+		zap.Duration("open_for", time.Since(slot.PostedAt)),
+	)
+	return best, nil
+}
+
+func (b *BackfillManager) takeSlot(ctx context.Context, slot *BackfillSlot) error {
+	reply, err := b.cache.Eval(ctx, takeBackfillSlotScript, []string{cache.MatchBackfillKey(slot.GameMode)}, slot.ID)
+	if err != nil {
+		return fmt.Errorf("failed to take backfill slot: %w", err)
+	}
+	if data, _ := reply.(string); data == "" {
+		return ErrBackfillSlotTaken
+	}
+	return nil
+}
+
+// 补位失败时重新发布空位，保留原发布时间
+func (b *BackfillManager) restoreSlot(ctx context.Context, slot *BackfillSlot) {
+	if err := b.PostSlot(ctx, slot); err != nil {
+		b.logger.GetLogger().Error("failed to restore backfill slot",
+			zap.String("slot_id", slot.ID),
+			zap.Error(err),
+		)
+	}
+}
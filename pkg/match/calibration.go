@@ -0,0 +1,172 @@
+package match
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mangooer/gamehub-arena/internal/config"
+	"github.com/mangooer/gamehub-arena/internal/logger"
+	"github.com/mangooer/gamehub-arena/internal/metrics"
+	"github.com/mangooer/gamehub-arena/internal/models"
+	"github.com/mangooer/gamehub-arena/internal/repository"
+	"github.com/mangooer/gamehub-arena/pkg/algorithm"
+	"go.uber.org/zap"
+)
+
+// CalibrationJob 定期按算法版本评估成局预测的校准程度
+//
+// 将最近 window 秒内成局、已有比赛结果的队伍获胜概率与实际胜负对比，
+// 计算 Brier 分数、对数损失和可靠性曲线，保存到 algorithm_calibrations 并更新 Prometheus 指标。
+// 同一时间窗口内两个算法版本的分数可以直接比较，越小越好。
+type CalibrationJob struct {
+	repo    *repository.MatchRepository
+	metrics *metrics.Metrics
+	config  *config.MatchConfig
+	logger  logger.Logger
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewCalibrationJob 创建校准任务，metrics 为空时只保存结果
+func NewCalibrationJob(repo *repository.MatchRepository, metrics *metrics.Metrics, config *config.MatchConfig, logger logger.Logger) *CalibrationJob {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &CalibrationJob{
+		repo:    repo,
+		metrics: metrics,
+		config:  config,
+		logger:  logger,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+func (j *CalibrationJob) Start() error {
+	if !j.config.Calibration.Enabled {
+		return nil
+	}
+	if j.config.Calibration.Interval <= 0 || j.config.Calibration.Window <= 0 {
+		return fmt.Errorf("invalid calibration interval %d or window %d", j.config.Calibration.Interval, j.config.Calibration.Window)
+	}
+
+	j.logger.GetLogger().Info("Starting calibration job",
+		zap.Int("interval", j.config.Calibration.Interval),
+		zap.Int("window", j.config.Calibration.Window),
+	)
+	j.wg.Add(1)
+	go j.run()
+	return nil
+}
+
+func (j *CalibrationJob) Stop() error {
+	j.cancel()
+	j.wg.Wait()
+	return nil
+}
+
+func (j *CalibrationJob) run() {
+	defer j.wg.Done()
+	ticker := time.NewTicker(time.Duration(j.config.Calibration.Interval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		if _, err := j.RunOnce(j.ctx, time.Now()); err != nil {
+			j.logger.GetLogger().Error("failed to run calibration", zap.Error(err))
+		}
+		select {
+		case <-j.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce 计算 [now-window, now) 内成局的对局的校准结果并保存，返回各算法版本的结果
+// 样本数少于 min_samples 的算法版本不保存
+func (j *CalibrationJob) RunOnce(ctx context.Context, now time.Time) ([]*models.AlgorithmCalibration, error) {
+	start := now.Add(-time.Duration(j.config.Calibration.Window) * time.Second)
+	outcomes, err := j.repo.ListPredictionOutcomes(start, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list prediction outcomes: %w", err)
+	}
+
+	samples := make(map[string][]algorithm.CalibrationSample)
+	for _, outcome := range outcomes {
+		samples[outcome.AlgorithmVersion] = append(samples[outcome.AlgorithmVersion], algorithm.CalibrationSample{
+			Predicted: outcome.WinProbability,
+			Won:       outcome.Won,
+		})
+	}
+	versions := make([]string, 0, len(samples))
+	for version := range samples {
+		versions = append(versions, version)
+	}
+	sort.Strings(versions)
+
+	var calibrations []*models.AlgorithmCalibration
+	for _, version := range versions {
+		if len(samples[version]) < j.config.Calibration.MinSamples {
+			j.logger.GetLogger().Debug("not enough samples for calibration",
+				zap.String("algorithm_version", version),
+				zap.Int("samples", len(samples[version])),
+			)
+			continue
+		}
+
+		report := algorithm.Calibrate(samples[version], j.config.Calibration.Bins)
+		calibration := newCalibrationRecord(version, start, now, report)
+		if err := j.repo.CreateCalibration(calibration); err != nil {
+			return calibrations, fmt.Errorf("failed to save calibration for %s: %w", version, err)
+		}
+		j.recordMetrics(version, report)
+		calibrations = append(calibrations, calibration)
+
+		j.logger.GetLogger().Info("Prediction calibration updated",
+			zap.String("algorithm_version", version),
+			zap.Int("samples", report.Samples),
+			zap.Float64("brier_score", report.BrierScore),
+			zap.Float64("log_loss", report.LogLoss),
+		)
+	}
+	return calibrations, nil
+}
+
+func (j *CalibrationJob) recordMetrics(version string, report *algorithm.CalibrationReport) {
+	if j.metrics == nil {
+		return
+	}
+	j.metrics.RecordPredictionCalibration(version, report.Samples, report.BrierScore, report.LogLoss)
+	for _, bin := range report.Reliability {
+		if bin.Count > 0 {
+			j.metrics.RecordPredictionReliability(version, strconv.FormatFloat(bin.Lower, 'f', 2, 64), bin.ObservedRate)
+		}
+	}
+}
+
+func newCalibrationRecord(version string, start, end time.Time, report *algorithm.CalibrationReport) *models.AlgorithmCalibration {
+	calibration := &models.AlgorithmCalibration{
+		AlgorithmVersion: version,
+		WindowStart:      start,
+		WindowEnd:        end,
+		SampleCount:      report.Samples,
+		BrierScore:       report.BrierScore,
+		LogLoss:          report.LogLoss,
+		CreatedAt:        end,
+		Bins:             make([]models.CalibrationBin, 0, len(report.Reliability)),
+	}
+	for _, bin := range report.Reliability {
+		calibration.Bins = append(calibration.Bins, models.CalibrationBin{
+			LowerBound:    bin.Lower,
+			UpperBound:    bin.Upper,
+			SampleCount:   bin.Count,
+			MeanPredicted: bin.MeanPredicted,
+			ObservedRate:  bin.ObservedRate,
+		})
+	}
+	return calibration
+}
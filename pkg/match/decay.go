@@ -0,0 +1,247 @@
+package match
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mangooer/gamehub-arena/internal/cache"
+	"github.com/mangooer/gamehub-arena/internal/config"
+	"github.com/mangooer/gamehub-arena/internal/logger"
+	"github.com/mangooer/gamehub-arena/internal/models"
+	"github.com/mangooer/gamehub-arena/internal/repository"
+	"go.uber.org/zap"
+)
+
+const decayDay = 24 * time.Hour
+
+// DecayJob 定期对长期未对局的高段位玩家衰减分数
+//
+// 玩家在段位配置的 inactive_days 内没有任何对局后，每满一天分数下降 points_per_day，
+// 不低于段位下限 floor，同时 RD 和 sigma 按平方和增长到上限，表示评级不确定度变大。
+// 每次衰减写入一条 change_reason 为 decay 的排行榜历史，并同步排行榜缓存。
+// 赛季排行榜只衰减配置的当前赛季，已结束的赛季保持不变。
+type DecayJob struct {
+	repo        *repository.LeaderboardRepository
+	leaderboard *cache.LeaderboardCacheService
+	config      *config.MatchConfig
+	logger      logger.Logger
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewDecayJob 创建分数衰减任务，leaderboard 为空时不更新缓存
+func NewDecayJob(repo *repository.LeaderboardRepository, leaderboard *cache.LeaderboardCacheService, config *config.MatchConfig, logger logger.Logger) *DecayJob {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &DecayJob{
+		repo:        repo,
+		leaderboard: leaderboard,
+		config:      config,
+		logger:      logger,
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+}
+
+func (j *DecayJob) Start() error {
+	if !j.config.Decay.Enabled {
+		return nil
+	}
+	if j.config.Decay.Interval <= 0 || j.config.Decay.BatchSize <= 0 {
+		return fmt.Errorf("invalid decay interval %d or batch size %d", j.config.Decay.Interval, j.config.Decay.BatchSize)
+	}
+	for tier, tierConfig := range j.config.Decay.Tiers {
+		if tierConfig.InactiveDays <= 0 || tierConfig.PointsPerDay < 0 {
+			return fmt.Errorf("invalid decay config for tier %s", tier)
+		}
+	}
+
+	j.logger.GetLogger().Info("Starting decay job",
+		zap.Int("interval", j.config.Decay.Interval),
+		zap.Int("tiers", len(j.config.Decay.Tiers)),
+	)
+	j.wg.Add(1)
+	go j.run()
+	return nil
+}
+
+func (j *DecayJob) Stop() error {
+	j.cancel()
+	j.wg.Wait()
+	return nil
+}
+
+func (j *DecayJob) run() {
+	defer j.wg.Done()
+	ticker := time.NewTicker(time.Duration(j.config.Decay.Interval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		if _, err := j.RunOnce(j.ctx, time.Now()); err != nil {
+			j.logger.GetLogger().Error("failed to run decay", zap.Error(err))
+		}
+		select {
+		case <-j.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce 对截至 now 满足不活跃条件的条目执行衰减，返回衰减的条目数
+// 衰减按整天计算，不足一天的部分留到下次，重复执行不会重复衰减
+func (j *DecayJob) RunOnce(ctx context.Context, now time.Time) (int, error) {
+	if len(j.config.Decay.LeaderboardTypes) == 0 {
+		return 0, nil
+	}
+	tiers := make([]string, 0, len(j.config.Decay.Tiers))
+	for tier := range j.config.Decay.Tiers {
+		tiers = append(tiers, tier)
+	}
+	sort.Strings(tiers)
+
+	decayed := 0
+	for _, tier := range tiers {
+		n, err := j.decayTier(ctx, tier, j.config.Decay.Tiers[tier], now)
+		decayed += n
+		if err != nil {
+			return decayed, fmt.Errorf("failed to decay tier %s: %w", tier, err)
+		}
+	}
+	if decayed > 0 {
+		j.logger.GetLogger().Info("Leaderboard decay applied", zap.Int("entries", decayed))
+	}
+	return decayed, nil
+}
+
+func (j *DecayJob) decayTier(ctx context.Context, tier string, tierConfig config.DecayTierConfig, now time.Time) (int, error) {
+	inactiveBefore := now.Add(-time.Duration(tierConfig.InactiveDays) * decayDay)
+	decayed := 0
+	var afterID uint64
+	for {
+		if err := ctx.Err(); err != nil {
+			return decayed, err
+		}
+		candidates, err := j.repo.ListDecayCandidates(tier, j.config.Decay.LeaderboardTypes, j.config.Decay.Season, tierConfig.Floor, inactiveBefore, afterID, j.config.Decay.BatchSize)
+		if err != nil {
+			return decayed, err
+		}
+		for i := range candidates {
+			afterID = candidates[i].ID
+			ok, err := j.decayEntry(ctx, &candidates[i], tierConfig, now)
+			if err != nil {
+				return decayed, err
+			}
+			if ok {
+				decayed++
+			}
+		}
+		if len(candidates) < j.config.Decay.BatchSize {
+			return decayed, nil
+		}
+	}
+}
+
+// 衰减单个条目，条目已衰减到当前时间或被并发修改时跳过
+func (j *DecayJob) decayEntry(ctx context.Context, candidate *repository.DecayCandidate, tierConfig config.DecayTierConfig, now time.Time) (bool, error) {
+	entry := &candidate.Leaderboard
+	since := candidate.LastPlayedAt.Add(time.Duration(tierConfig.InactiveDays) * decayDay)
+	if entry.LastDecayAt != nil && entry.LastDecayAt.After(since) {
+		since = *entry.LastDecayAt
+	}
+	days := int(now.Sub(since) / decayDay)
+	if days <= 0 {
+		return false, nil
+	}
+
+	oldScore := entry.Score
+	entry.Score = max(tierConfig.Floor, oldScore-int64(days)*tierConfig.PointsPerDay)
+	entry.Tier = j.decayedTier(entry.Tier, entry.Score)
+	entry.RatingDeviation = inflateUncertainty(entry.RatingDeviation, j.config.Decay.InitialRD, j.config.Decay.RDPerDay, j.config.Decay.MaxRD, days)
+	entry.Sigma = inflateUncertainty(entry.Sigma, j.config.Decay.InitialSigma, j.config.Decay.SigmaPerDay, j.config.Decay.MaxSigma, days)
+	decayedTo := since.Add(time.Duration(days) * decayDay)
+	entry.LastDecayAt = &decayedTo
+	entry.LastUpdated = now
+
+	history := &models.LeaderboardHistory{
+		UserID:          entry.UserID,
+		LeaderboardType: entry.LeaderboardType,
+		OldRank:         entry.RankPosition,
+		OldScore:        oldScore,
+		NewScore:        entry.Score,
+		ChangeReason:    models.LeaderboardChangeDecay,
+		CreatedAt:       now,
+	}
+	if err := j.repo.ApplyDecay(entry, oldScore, history); err != nil {
+		if errors.Is(err, repository.ErrLeaderboardChanged) {
+			j.logger.GetLogger().Debug("leaderboard entry changed during decay",
+				zap.Uint64("leaderboard_id", entry.ID),
+				zap.Uint64("user_id", entry.UserID),
+			)
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to apply decay to leaderboard %d: %w", entry.ID, err)
+	}
+
+	if j.leaderboard != nil && entry.Score != oldScore {
+		if err := j.leaderboard.UpdateUserScore(ctx, cache.LeaderboardName(entry.LeaderboardType, entry.GameMode, entry.Season, entry.Region), entry.UserID, float64(entry.Score)); err != nil {
+			j.logger.GetLogger().Warn("failed to update leaderboard cache after decay",
+				zap.Uint64("user_id", entry.UserID),
+				zap.String("leaderboard_type", entry.LeaderboardType),
+				zap.Error(err),
+			)
+		}
+	}
+
+	j.logger.GetLogger().Debug("Leaderboard entry decayed",
+		zap.Uint64("user_id", entry.UserID),
+		zap.String("leaderboard_type", entry.LeaderboardType),
+		zap.String("tier", entry.Tier),
+		zap.Int("days", days),
+		zap.Int64("old_score", oldScore),
+		zap.Int64("new_score", entry.Score),
+	)
+	return true, nil
+}
+
+// 衰减后的段位：分数低于当前段位下限时降到下限不高于分数的最高段位，只降不升
+// 当前段位或分数对应的段位未配置时保持不变
+func (j *DecayJob) decayedTier(tier string, score int64) string {
+	current, ok := j.config.Decay.Tiers[strings.ToLower(tier)]
+	if !ok || score >= current.Floor {
+		return tier
+	}
+	decayed, floor := tier, int64(math.MinInt64)
+	for name, tierConfig := range j.config.Decay.Tiers {
+		if tierConfig.Floor <= score && tierConfig.Floor > floor {
+			decayed, floor = name, tierConfig.Floor
+		}
+	}
+	return decayed
+}
+
+// 不确定度按平方和随不活跃天数增长，不超过上限；为空表示仍为初始值，从 initial 开始增长
+func inflateUncertainty(current *float64, initial, perDay, limit float64, days int) *float64 {
+	if perDay <= 0 {
+		return current
+	}
+	value := initial
+	if current != nil {
+		value = *current
+	}
+	if value <= 0 {
+		return current
+	}
+	inflated := math.Sqrt(value*value + perDay*perDay*float64(days))
+	if limit > 0 {
+		inflated = math.Min(limit, inflated)
+	}
+	return &inflated
+}
@@ -0,0 +1,121 @@
+package match
+
+import (
+	"math"
+	"testing"
+
+	"github.com/mangooer/gamehub-arena/internal/config"
+)
+
+func TestInflateUncertainty(t *testing.T) {
+	value := func(v float64) *float64 { return &v }
+	tests := []struct {
+		name    string
+		current *float64
+		initial float64
+		perDay  float64
+		limit   float64
+		days    int
+		want    *float64
+	}{
+		{
+			name:    "按平方和增长",
+			current: value(100),
+			initial: 350,
+			perDay:  25,
+			limit:   350,
+			days:    16,
+			want:    value(math.Sqrt(100*100 + 25*25*16)),
+		},
+		{
+			name:    "不超过上限",
+			current: value(300),
+			initial: 350,
+			perDay:  50,
+			limit:   350,
+			days:    30,
+			want:    value(350),
+		},
+		{
+			name:    "上限不大于0时不限制",
+			current: value(300),
+			initial: 350,
+			perDay:  50,
+			days:    30,
+			want:    value(math.Sqrt(300*300 + 50*50*30)),
+		},
+		{
+			name:    "为空时从初始值开始增长",
+			initial: 0.06,
+			perDay:  0.01,
+			limit:   0.1,
+			days:    4,
+			want:    value(math.Sqrt(0.06*0.06 + 0.01*0.01*4)),
+		},
+		{
+			name:    "为空且初始值达到上限",
+			initial: 350,
+			perDay:  10,
+			limit:   350,
+			days:    1,
+			want:    value(350),
+		},
+		{
+			name:    "未配置增长量时不变",
+			current: value(100),
+			initial: 350,
+			limit:   350,
+			days:    10,
+			want:    value(100),
+		},
+		{
+			name:   "为空且没有初始值时不变",
+			perDay: 10,
+			limit:  350,
+			days:   10,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := inflateUncertainty(tt.current, tt.initial, tt.perDay, tt.limit, tt.days)
+			if (got == nil) != (tt.want == nil) {
+				t.Fatalf("inflateUncertainty() = %v, want %v", got, tt.want)
+			}
+			if got != nil && math.Abs(*got-*tt.want) > 1e-9 {
+				t.Errorf("inflateUncertainty() = %v, want %v", *got, *tt.want)
+			}
+		})
+	}
+}
+
+func TestDecayedTier(t *testing.T) {
+	job := &DecayJob{config: &config.MatchConfig{Decay: config.DecayConfig{
+		Tiers: map[string]config.DecayTierConfig{
+			"diamond":  {Floor: 2400},
+			"platinum": {Floor: 2000},
+			"gold":     {Floor: 1600},
+		},
+	}}}
+	tests := []struct {
+		name  string
+		tier  string
+		score int64
+		want  string
+	}{
+		{name: "仍在段位下限之上", tier: "diamond", score: 2450, want: "diamond"},
+		{name: "降一个段位", tier: "diamond", score: 2350, want: "platinum"},
+		{name: "跨段位下降", tier: "diamond", score: 1700, want: "gold"},
+		{name: "段位名称不区分大小写", tier: "Diamond", score: 2350, want: "platinum"},
+		{name: "低于所有配置的段位时保持不变", tier: "gold", score: 1500, want: "gold"},
+		{name: "未配置的段位不变", tier: "master", score: 100, want: "master"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := job.decayedTier(tt.tier, tt.score); got != tt.want {
+				t.Errorf("decayedTier(%q, %d) = %q, want %q", tt.tier, tt.score, got, tt.want)
+			}
+		})
+	}
+}
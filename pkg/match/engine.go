@@ -2,6 +2,7 @@ package match
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -14,8 +15,13 @@ import (
 )
 
 type MatchingEngine struct {
-	algorithm    algorithm.MatchingAlgorithm
+	// 生效中的算法组，匹配期间持有读锁，切换时持有写锁等待进行中的匹配完成
+	algorithms   *algorithmSet
+	algorithmMu  sync.RWMutex
 	queueManager *QueueManager
+	readyChecks  *ReadyCheckManager
+	backfill     *BackfillManager
+	shards       *ShardManager // 多实例分片，未开启时为空，处理所有桶
 	cache        cache.CacheService
 	config       *config.Config
 	logger       logger.Logger
@@ -28,6 +34,12 @@ type MatchingEngine struct {
 	// 统计信息
 	stats   *EngineStats
 	statsMu sync.RWMutex
+
+	// 上一轮补位后仍然空缺的位置及该轮开始时间。之后入队、可以补位的玩家
+	// 在下一轮补位评估之前不参与普通匹配，保证补位优先
+	openSlots      map[string][]*BackfillSlot
+	slotsCheckedAt map[string]time.Time
+	slotsMu        sync.RWMutex
 }
 
 type EngineStats struct {
@@ -46,8 +58,37 @@ type MMRRange struct {
 	MaxMMR float64 `json:"max_mmr"`
 }
 
-func NewMatchingEngine(algorithmName string, queueManager *QueueManager, cache cache.CacheService, config *config.Config, logger logger.Logger) (*MatchingEngine, error) {
+// 段位协程处理的MMR段
+var mmrRanks = []MMRRange{
+	{Name: "Beginner", MinMMR: 0, MaxMMR: 1000},
+	{Name: "Bronze", MinMMR: 800, MaxMMR: 1200},       // 有重叠
+	{Name: "Silver", MinMMR: 1100, MaxMMR: 1400},      // 有重叠
+	{Name: "Gold", MinMMR: 1300, MaxMMR: 1600},        // 有重叠
+	{Name: "Platinum", MinMMR: 1500, MaxMMR: 1800},    // 有重叠
+	{Name: "Diamond", MinMMR: 1700, MaxMMR: 2100},     // 有重叠
+	{Name: "Master", MinMMR: 2000, MaxMMR: 2500},      // 有重叠
+	{Name: "Grandmaster", MinMMR: 2300, MaxMMR: 3000}, // 有重叠
+}
+
+// 所有模式、区域和MMR段组成的匹配桶
+func matchBuckets(cfg *config.MatchConfig) []MatchBucket {
+	var buckets []MatchBucket
+	for _, gameMode := range cfg.Queue.GameModes {
+		for _, region := range cfg.RegionNames() {
+			for _, rank := range mmrRanks {
+				buckets = append(buckets, MatchBucket{GameMode: gameMode, Region: region, Rank: rank})
+			}
+		}
+	}
+	return buckets
+}
+
+// NewMatchingEngine 创建匹配引擎，使用 match.default_algorithm 指定的算法
+func NewMatchingEngine(queueManager *QueueManager, readyChecks *ReadyCheckManager, backfill *BackfillManager, cache cache.CacheService, config *config.Config, logger logger.Logger) (*MatchingEngine, error) {
 	factory := algorithm.InitFactory()
+	factory.Configure(&config.Match)
+
+	algorithmName := config.Match.DefaultAlgorithm
 	algorithm, err := factory.GetAlgorithm(algorithmName)
 	if err != nil {
 		logger.GetLogger().Error("failed to get algorithm",
@@ -56,17 +97,43 @@ func NewMatchingEngine(algorithmName string, queueManager *QueueManager, cache c
 		)
 		return nil, err
 	}
+	challenger, err := newChallenger(factory, algorithm, config.Match.Experiment)
+	if err != nil {
+		logger.GetLogger().Error("failed to start experiment",
+			zap.String("challenger", config.Match.Experiment.Challenger),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+
+	var shards *ShardManager
+	if config.Match.Sharding.Enabled {
+		if config.Match.Sharding.LeaseTTL <= 0 {
+			return nil, fmt.Errorf("invalid sharding lease ttl: %d", config.Match.Sharding.LeaseTTL)
+		}
+		shards = NewShardManager(cache, &config.Match, matchBuckets(&config.Match), logger)
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 
 	return &MatchingEngine{
-		algorithm:    algorithm,
-		queueManager: queueManager,
-		cache:        cache,
-		config:       config,
-		logger:       logger,
-		ctx:          ctx,
-		cancel:       cancel,
+		algorithms: &algorithmSet{
+			control:    algorithm,
+			challenger: challenger,
+			percent:    config.Match.Experiment.TrafficPercent,
+			salt:       config.Match.Experiment.Salt,
+		},
+		queueManager:   queueManager,
+		readyChecks:    readyChecks,
+		backfill:       backfill,
+		shards:         shards,
+		openSlots:      make(map[string][]*BackfillSlot),
+		slotsCheckedAt: make(map[string]time.Time),
+		cache:          cache,
+		config:         config,
+		logger:         logger,
+		ctx:            ctx,
+		cancel:         cancel,
 		stats: &EngineStats{
 			TotalRequests:     0,
 			SuccessfulMatches: 0,
@@ -80,32 +147,43 @@ func NewMatchingEngine(algorithmName string, queueManager *QueueManager, cache c
 }
 
 func (e *MatchingEngine) Start() error {
-	e.logger.GetLogger().Info("Starting matching engine",
-		zap.String("algorithm", e.algorithm.Name()),
-		zap.String("version", e.algorithm.Version()),
-	)
+	e.algorithmMu.RLock()
+	algorithms := e.algorithms
+	e.algorithmMu.RUnlock()
+	fields := []zap.Field{
+		zap.String("algorithm", algorithms.control.Name()),
+		zap.String("version", algorithms.control.Version()),
+	}
+	if algorithms.challenger != nil {
+		fields = append(fields,
+			zap.String("challenger", algorithms.challenger.Name()),
+			zap.Float64("traffic_percent", algorithms.percent),
+		)
+	}
+	e.logger.GetLogger().Info("Starting matching engine", fields...)
+	// 开启分片时先获取租约，各段位协程只处理本实例持有租约的桶
+	if e.shards != nil {
+		e.logger.GetLogger().Info("Bucket sharding enabled", zap.String("instance_id", e.shards.InstanceID()))
+		e.wg.Add(1)
+		go e.maintainLeases()
+	}
 	// 启动多个携程处理不同等级的队列
-	ranks := []MMRRange{
-		{Name: "Beginner", MinMMR: 0, MaxMMR: 1000},
-		{Name: "Bronze", MinMMR: 800, MaxMMR: 1200},       // 有重叠
-		{Name: "Silver", MinMMR: 1100, MaxMMR: 1400},      // 有重叠
-		{Name: "Gold", MinMMR: 1300, MaxMMR: 1600},        // 有重叠
-		{Name: "Platinum", MinMMR: 1500, MaxMMR: 1800},    // 有重叠
-		{Name: "Diamond", MinMMR: 1700, MaxMMR: 2100},     // 有重叠
-		{Name: "Master", MinMMR: 2000, MaxMMR: 2500},      // 有重叠
-		{Name: "Grandmaster", MinMMR: 2300, MaxMMR: 3000}, // 有重叠
-	}
-
-	for _, rank := range ranks {
+	for _, rank := range mmrRanks {
 		e.wg.Add(1)
 		go e.processRankQueue(rank)
 	}
+	// 启动补位协程，优先为进行中的房间补位
+	e.wg.Add(1)
+	go e.processBackfill()
 	// 启动统计监控协程
 	e.wg.Add(1)
 	go e.monitorMatchingStats()
 	// 启动定期清理超时匹配协程
 	e.wg.Add(1)
 	go e.cleanupExpiredMatches()
+	// 启动就绪确认超时处理协程
+	e.wg.Add(1)
+	go e.expireReadyChecks()
 	return nil
 }
 
@@ -115,6 +193,13 @@ func (e *MatchingEngine) Stop() error {
 	e.cancel()
 	e.wg.Wait()
 
+	// 释放租约，其他实例无需等待过期即可接管
+	if e.shards != nil {
+		if err := e.shards.ReleaseAll(context.Background()); err != nil {
+			e.logger.GetLogger().Error("failed to release bucket leases", zap.Error(err))
+		}
+	}
+
 	e.logger.GetLogger().Info("Matching engine stopped")
 	return nil
 }
@@ -134,6 +219,12 @@ func (e *MatchingEngine) FindMatch(ctx context.Context, player *algorithm.Player
 		})
 	}()
 
+	// 匹配全程持有读锁，算法切换会等待本次匹配完成
+	e.algorithmMu.RLock()
+	defer e.algorithmMu.RUnlock()
+	arm := e.algorithms.armOf(player)
+	matcher := e.algorithms.algorithmFor(arm)
+
 	// 获取候选玩家
 	candidates, err := e.queueManager.GetCandidates(ctx, player)
 	if err != nil {
@@ -142,15 +233,146 @@ func (e *MatchingEngine) FindMatch(ctx context.Context, player *algorithm.Player
 		})
 		return nil, fmt.Errorf("failed to get candidates: %w", err)
 	}
-
-	// 寻找最佳匹配
-	result, err := e.algorithm.FindOptimalMatch(ctx, player, candidates)
+	candidates = e.algorithms.sameArm(arm, candidates)
+
+	// 寻找最佳匹配，团队模式组建完整对局并分队
+	var result *algorithm.MatchResult
+	if teamSize := e.config.Match.TeamSize(player.GameMode); teamSize > 1 {
+		result, err = matcher.FindTeamMatch(ctx, player, candidates, algorithm.TeamFormat{
+			Size:     teamSize,
+			Roles:    e.config.Match.Roles(player.GameMode),
+			Feasible: e.dataCenterFeasible,
+		})
+	} else {
+		result, err = matcher.FindOptimalMatch(ctx, player, candidates)
+		if err == nil && len(result.Teams) == 0 {
+			// 单人对战每人一队
+			result.Teams, _ = algorithm.BalanceTeams(result.Players, 1)
+		}
+	}
 	if err != nil {
 		e.updateStats(func(stats *EngineStats) {
 			stats.FailedMatches++
 		})
 		return nil, fmt.Errorf("failed to find match: %w", err)
 	}
+	if err := e.commitMatch(ctx, result, arm, matcher, startTime); err != nil {
+		e.updateStats(func(stats *EngineStats) {
+			stats.FailedMatches++
+		})
+		return nil, err
+	}
+	return result, nil
+}
+
+// FindBatchMatches 对同一模式的一批玩家全局优化组局，依次认领并发起就绪确认
+// 各实验分组分别求解；认领失败的对局跳过，其玩家留在队列中等待下一轮
+func (e *MatchingEngine) FindBatchMatches(ctx context.Context, gameMode string, players []*algorithm.Player) ([]*algorithm.MatchResult, error) {
+	startTime := time.Now()
+
+	e.algorithmMu.RLock()
+	defer e.algorithmMu.RUnlock()
+	arms := make(map[string][]*algorithm.Player)
+	for _, player := range players {
+		arm := e.algorithms.armOf(player)
+		arms[arm] = append(arms[arm], player)
+	}
+
+	var matches []*algorithm.MatchResult
+	for _, arm := range []string{ArmControl, ArmChallenger} {
+		if len(arms[arm]) == 0 {
+			continue
+		}
+		matcher := e.algorithms.algorithmFor(arm)
+		results, err := matcher.FindBatchMatches(ctx, arms[arm], algorithm.BatchOptions{
+			Format: algorithm.TeamFormat{
+				Size:     e.config.Match.TeamSize(gameMode),
+				Roles:    e.config.Match.Roles(gameMode),
+				Feasible: e.dataCenterFeasible,
+			},
+			TimeBudget:    time.Duration(e.config.Match.Batch.TimeBudget) * time.Millisecond,
+			MaxIterations: e.config.Match.Batch.MaxIterations,
+			Seed:          startTime.UnixNano(),
+			Compatible:    e.queueManager.Compatible,
+		})
+		if err != nil {
+			return matches, fmt.Errorf("failed to find batch matches: %w", err)
+		}
+
+		for _, result := range results {
+			duration := time.Since(startTime)
+			e.updateStats(func(stats *EngineStats) {
+				stats.TotalRequests++
+				stats.AverageMatchTime = (stats.AverageMatchTime*time.Duration(stats.TotalRequests-1) + duration) / time.Duration(stats.TotalRequests)
+			})
+			if err := e.commitMatch(ctx, result, arm, matcher, startTime); err != nil {
+				e.updateStats(func(stats *EngineStats) {
+					stats.FailedMatches++
+				})
+				if errors.Is(err, ErrPlayerClaimed) {
+					e.logger.GetLogger().Debug("batch match already claimed",
+						zap.String("match_id", result.MatchID),
+					)
+					continue
+				}
+				if errors.Is(err, ErrLeaseLost) {
+					return matches, err
+				}
+				e.logger.GetLogger().Error("failed to commit batch match",
+					zap.String("match_id", result.MatchID),
+					zap.Error(err),
+				)
+				continue
+			}
+			matches = append(matches, result)
+		}
+	}
+	return matches, nil
+}
+
+// 为算法给出的对局补充元数据、预测和机房，认领玩家并发起就绪确认
+func (e *MatchingEngine) commitMatch(ctx context.Context, result *algorithm.MatchResult, arm string, matcher algorithm.MatchingAlgorithm, startTime time.Time) error {
+	result.CreatedAt = time.Now()
+	if result.Metadata == nil {
+		result.Metadata = make(map[string]interface{})
+	}
+	result.Metadata["experiment_arm"] = arm
+	result.Metadata["algorithm_version"] = matcher.Version()
+
+	// 预测胜负，预测失败不影响成局
+	if prediction, err := matcher.PredictOutcome(ctx, result.Teams); err != nil {
+		e.logger.GetLogger().Warn("failed to predict match outcome",
+			zap.String("match_id", result.MatchID),
+			zap.Error(err),
+		)
+	} else {
+		result.Prediction = prediction
+	}
+
+	// 选择使对局最大延迟最小的机房
+	if err := e.assignDataCenter(result); err != nil {
+		return fmt.Errorf("failed to select data center: %w", err)
+	}
+
+	// 原子认领所有玩家，防止重叠段位协程重复匹配同一玩家
+	entries, err := e.queueManager.ClaimMatch(ctx, result)
+	if err != nil {
+		return fmt.Errorf("failed to claim match: %w", err)
+	}
+
+	// 发起就绪确认，失败时将玩家放回队列
+	if _, err := e.readyChecks.Start(ctx, result, entries); err != nil {
+		for _, entry := range entries {
+			if requeueErr := e.queueManager.Requeue(ctx, entry); requeueErr != nil {
+				e.logger.GetLogger().Error("failed to requeue unit",
+					zap.String("match_id", result.MatchID),
+					zap.String("unit", entry.unitMember()),
+					zap.Error(requeueErr),
+				)
+			}
+		}
+		return fmt.Errorf("failed to start ready check: %w", err)
+	}
 
 	e.updateStats(func(stats *EngineStats) {
 		stats.SuccessfulMatches++
@@ -160,11 +382,45 @@ func (e *MatchingEngine) FindMatch(ctx context.Context, player *algorithm.Player
 		zap.String("match_id", result.MatchID),
 		zap.Float64("quality", result.Quality),
 		zap.Int("players", len(result.Players)),
+		zap.Int("teams", len(result.Teams)),
+		zap.String("arm", arm),
+		zap.String("data_center", result.DataCenter),
 		zap.Duration("duration", time.Since(startTime)),
 	)
 
-	return result, nil
+	return nil
+}
 
+// 在对局玩家所在区域的机房中选出最大延迟最小的机房，延迟不超过所有玩家搜索窗口的上限
+func (e *MatchingEngine) assignDataCenter(result *algorithm.MatchResult) error {
+	dataCenter, maxPing, err := e.selectDataCenter(result.Players)
+	if err != nil {
+		return err
+	}
+	crossRegion := false
+	for _, p := range result.Players {
+		crossRegion = crossRegion || p.Region != result.Players[0].Region
+	}
+	result.DataCenter = dataCenter
+	result.Metadata["max_ping"] = maxPing
+	result.Metadata["cross_region"] = crossRegion
+	return nil
+}
+
+func (e *MatchingEngine) selectDataCenter(players []*algorithm.Player) (string, int, error) {
+	var regions []string
+	pingLimit := 0
+	for _, p := range players {
+		regions = append(regions, p.Region)
+		pingLimit = TighterLimit(pingLimit, e.queueManager.SearchWindow(p).MaxPing)
+	}
+	return algorithm.SelectDataCenter(players, e.config.Match.DataCenters(regions...), pingLimit)
+}
+
+// 对局是否有满足所有玩家延迟上限的机房，组局时排除无法选出机房的对局
+func (e *MatchingEngine) dataCenterFeasible(players []*algorithm.Player) bool {
+	_, _, err := e.selectDataCenter(players)
+	return err == nil
 }
 
 func (e *MatchingEngine) updateStats(fn func(*EngineStats)) {
@@ -179,18 +435,94 @@ func (e *MatchingEngine) GetStats() *EngineStats {
 	return e.stats
 }
 
+// SwitchAlgorithm 替换对照组算法，等待进行中的匹配完成后生效，实验分组保持不变
 func (e *MatchingEngine) SwitchAlgorithm(algorithmName string) error {
-
 	factory := algorithm.InitFactory()
-	algorithm, err := factory.GetAlgorithm(algorithmName)
+	next, err := factory.GetAlgorithm(algorithmName)
 	if err != nil {
 		return fmt.Errorf("failed to get algorithm: %w", err)
 	}
-	e.algorithm = algorithm
+
+	e.algorithmMu.Lock()
+	defer e.algorithmMu.Unlock()
+	if next == e.algorithms.challenger {
+		return fmt.Errorf("%w: %s is the challenger algorithm", ErrInvalidExperiment, algorithmName)
+	}
+	updated := *e.algorithms
+	updated.control = next
+	e.algorithms = &updated
+
 	e.logger.GetLogger().Info("Algorithm switched", zap.String("algorithm", algorithmName))
 	return nil
 }
 
+// StartExperiment 开启A/B实验，trafficPercent% 的玩家按用户ID分桶后由挑战者算法匹配
+func (e *MatchingEngine) StartExperiment(challengerName string, trafficPercent float64, salt string) error {
+	e.algorithmMu.Lock()
+	defer e.algorithmMu.Unlock()
+
+	challenger, err := newChallenger(algorithm.InitFactory(), e.algorithms.control, config.ExperimentConfig{
+		Enabled:        true,
+		Challenger:     challengerName,
+		TrafficPercent: trafficPercent,
+		Salt:           salt,
+	})
+	if err != nil {
+		return err
+	}
+	// 挑战者统计从实验开始时计算
+	challenger.ResetStats()
+	e.algorithms = &algorithmSet{
+		control:    e.algorithms.control,
+		challenger: challenger,
+		percent:    trafficPercent,
+		salt:       salt,
+	}
+
+	e.logger.GetLogger().Info("Experiment started",
+		zap.String("control", e.algorithms.control.Name()),
+		zap.String("challenger", challengerName),
+		zap.Float64("traffic_percent", trafficPercent),
+	)
+	return nil
+}
+
+// StopExperiment 结束A/B实验，所有玩家回到对照组算法
+func (e *MatchingEngine) StopExperiment() {
+	e.algorithmMu.Lock()
+	defer e.algorithmMu.Unlock()
+
+	e.algorithms = &algorithmSet{control: e.algorithms.control}
+	e.logger.GetLogger().Info("Experiment stopped")
+}
+
+// GetArmStats 返回各实验分组的算法统计，未开启实验时只有对照组
+func (e *MatchingEngine) GetArmStats() []*ArmStats {
+	e.algorithmMu.RLock()
+	algorithms := e.algorithms
+	e.algorithmMu.RUnlock()
+
+	controlPercent := 100.0
+	var arms []*ArmStats
+	if algorithms.challenger != nil {
+		controlPercent -= algorithms.percent
+		arms = append(arms, &ArmStats{
+			Arm:            ArmChallenger,
+			Algorithm:      algorithms.challenger.Name(),
+			Version:        algorithms.challenger.Version(),
+			TrafficPercent: algorithms.percent,
+			Stats:          algorithms.challenger.GetStats(),
+		})
+	}
+	return append([]*ArmStats{{
+		Arm:            ArmControl,
+		Algorithm:      algorithms.control.Name(),
+		Version:        algorithms.control.Version(),
+		TrafficPercent: controlPercent,
+		Stats:          algorithms.control.GetStats(),
+	}}, arms...)
+}
+
 func (e *MatchingEngine) processRankQueue(rank MMRRange) {
 	defer e.wg.Done()
 	ticker := time.NewTicker(1 * time.Second)
@@ -200,19 +532,34 @@ func (e *MatchingEngine) processRankQueue(rank MMRRange) {
 		case <-e.ctx.Done():
 			return
 		case <-ticker.C:
-			e.processRankQueueOnce(rank)
+			for _, gameMode := range e.config.Match.Queue.GameModes {
+				for _, region := range e.config.Match.RegionNames() {
+					e.processBucketOnce(MatchBucket{GameMode: gameMode, Region: region, Rank: rank})
+				}
+			}
 		}
 	}
 }
 
-func (e *MatchingEngine) processRankQueueOnce(rank MMRRange) {
-	// 从队列获取等待匹配的玩家
-	players, err := e.queueManager.GetWaitingPlayersByMMRRange(e.ctx, rank)
+// 处理一个匹配桶，开启分片时只处理本实例持有租约的桶
+func (e *MatchingEngine) processBucketOnce(bucket MatchBucket) {
+	ctx := e.ctx
+	if e.shards != nil {
+		lease, ok := e.shards.Lease(bucket, time.Now())
+		if !ok {
+			return
+		}
+		ctx = withLease(ctx, lease)
+	}
+
+	// 从队列获取等待匹配的玩家，批量匹配的模式同时包含相邻区域已放宽到本区域的玩家
+	batch := e.config.Match.Matcher(bucket.GameMode) == config.MatcherBatch
+	players, err := e.queueManager.GetBucketPlayers(ctx, bucket, batch)
 	if err != nil {
 		e.logger.GetLogger().Error("failed to get waiting players",
-			zap.String("rank", rank.Name),
-			zap.Float64("min_mmr", rank.MinMMR),
-			zap.Float64("max_mmr", rank.MaxMMR),
+			zap.String("bucket", bucket.ID()),
+			zap.Float64("min_mmr", bucket.Rank.MinMMR),
+			zap.Float64("max_mmr", bucket.Rank.MaxMMR),
 			zap.Error(err),
 		)
 		return
@@ -222,19 +569,175 @@ func (e *MatchingEngine) processRankQueueOnce(rank MMRRange) {
 		return
 	}
 
+	// 低优先级玩家在额外等待结束前不参与匹配；优先级高的玩家先寻找对局
+	now := time.Now()
+	available := players[:0]
 	for _, player := range players {
-		if _, err := e.FindMatch(e.ctx, player); err != nil {
+		if Matchable(&e.config.Match, player, now) && !e.reservedForBackfill(player) {
+			available = append(available, player)
+		}
+	}
+	sortByPriority(available)
+
+	// 批量匹配的模式整批求解，其余模式逐个玩家贪心匹配
+	if batch {
+		if len(available) < 2 {
+			return
+		}
+		if _, err := e.FindBatchMatches(ctx, bucket.GameMode, available); err != nil {
+			e.logger.GetLogger().Error("failed to find batch matches",
+				zap.String("bucket", bucket.ID()),
+				zap.Int("players", len(available)),
+				zap.Error(err),
+			)
+		}
+		return
+	}
+
+	// 本轮已被匹配的玩家，避免为其重复寻找匹配
+	matched := make(map[uint64]bool)
+	for _, player := range available {
+		if matched[player.ID] {
+			continue
+		}
+		result, err := e.FindMatch(ctx, player)
+		if err != nil {
+			if errors.Is(err, ErrPlayerClaimed) {
+				// 已被其他段位协程认领，属于正常竞争
+				e.logger.GetLogger().Debug("match already claimed",
+					zap.Uint64("playerID", player.ID),
+					zap.String("bucket", bucket.ID()),
+				)
+				continue
+			}
+			if errors.Is(err, ErrLeaseLost) {
+				// 租约已被其他实例接管，本轮不再处理该桶
+				e.logger.GetLogger().Warn("bucket lease lost during matching",
+					zap.String("bucket", bucket.ID()),
+					zap.Error(err),
+				)
+				return
+			}
 			e.logger.GetLogger().Error("failed to find match",
-				zap.Int64("playerID", player.ID),
-				zap.String("rank", rank.Name),
-				zap.Float64("min_mmr", rank.MinMMR),
-				zap.Float64("max_mmr", rank.MaxMMR),
+				zap.Uint64("playerID", player.ID),
+				zap.String("bucket", bucket.ID()),
+				zap.Float64("min_mmr", bucket.Rank.MinMMR),
+				zap.Float64("max_mmr", bucket.Rank.MaxMMR),
 				zap.Error(err),
 			)
+			continue
+		}
+		for _, p := range result.Players {
+			matched[p.ID] = true
 		}
 	}
 }
 
+// 定期心跳并续约、调整本实例持有的匹配桶
+func (e *MatchingEngine) maintainLeases() {
+	defer e.wg.Done()
+	rebalance := func() {
+		if err := e.shards.Rebalance(e.ctx, time.Now()); err != nil && e.ctx.Err() == nil {
+			e.logger.GetLogger().Error("failed to rebalance bucket leases", zap.Error(err))
+		}
+	}
+	rebalance()
+
+	ticker := time.NewTicker(e.shards.RenewInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.ctx.Done():
+			return
+		case <-ticker.C:
+			rebalance()
+		}
+	}
+}
+
+func (e *MatchingEngine) processBackfill() {
+	defer e.wg.Done()
+	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.ctx.Done():
+			return
+		case <-ticker.C:
+			for _, gameMode := range e.config.Match.Queue.GameModes {
+				e.processBackfillOnce(gameMode)
+			}
+		}
+	}
+}
+
+// 按发布时间依次为模式下的空位补位
+// 开启分片时只处理本实例持有空位所属桶租约的空位，认领时同样校验隔离令牌
+func (e *MatchingEngine) processBackfillOnce(gameMode string) {
+	checkedAt := time.Now()
+	slots, err := e.backfill.OpenSlots(e.ctx, gameMode)
+	if err != nil {
+		e.logger.GetLogger().Error("failed to get backfill slots",
+			zap.String("game_mode", gameMode),
+			zap.Error(err),
+		)
+		return
+	}
+
+	e.algorithmMu.RLock()
+	matcher := e.algorithms.control
+	var open []*BackfillSlot
+	for _, slot := range slots {
+		ctx := e.ctx
+		if e.shards != nil {
+			lease, ok := e.shards.Lease(slot.bucket(), time.Now())
+			if !ok {
+				continue
+			}
+			ctx = withLease(ctx, lease)
+		}
+		player, err := e.backfill.FillSlot(ctx, matcher, slot)
+		if err != nil {
+			if !errors.Is(err, ErrBackfillSlotTaken) && !errors.Is(err, ErrPlayerClaimed) {
+				e.logger.GetLogger().Error("failed to fill backfill slot",
+					zap.String("slot_id", slot.ID),
+					zap.Error(err),
+				)
+			}
+			continue
+		}
+		if player == nil {
+			open = append(open, slot)
+		}
+	}
+	e.algorithmMu.RUnlock()
+
+	e.slotsMu.Lock()
+	e.openSlots[gameMode] = open
+	e.slotsCheckedAt[gameMode] = checkedAt
+	e.slotsMu.Unlock()
+}
+
+// 玩家是否为某个仍然空缺的补位位置保留
+// 已经过补位评估但未被选中的玩家不再保留，避免长期占用
+func (e *MatchingEngine) reservedForBackfill(player *algorithm.Player) bool {
+	if !player.AllowBackfill {
+		return false
+	}
+	e.slotsMu.RLock()
+	defer e.slotsMu.RUnlock()
+	if player.QueueTime.Before(e.slotsCheckedAt[player.GameMode]) {
+		return false
+	}
+	window := e.queueManager.SearchWindow(player)
+	for _, slot := range e.openSlots[player.GameMode] {
+		if slot.accepts(player, window) {
+			return true
+		}
+	}
+	return false
+}
+
 func (e *MatchingEngine) monitorMatchingStats() {
 	defer e.wg.Done()
 	ticker := time.NewTicker(30 * time.Second)
@@ -246,6 +749,9 @@ func (e *MatchingEngine) monitorMatchingStats() {
 		case <-ticker.C:
 			e.updateStats(func(stats *EngineStats) {
 				stats.QueueSize = e.queueManager.GetTotalQueueSize()
+				if e.shards != nil {
+					stats.ActiveWorkers = e.shards.OwnedBuckets()
+				}
 				stats.LastUpdated = time.Now()
 			})
 
@@ -258,6 +764,14 @@ func (e *MatchingEngine) monitorMatchingStats() {
 				zap.Int("queue_size", stats.QueueSize),
 				zap.Duration("avg_match_time", stats.AverageMatchTime),
 			)
+			for _, arm := range e.GetArmStats() {
+				e.logger.GetLogger().Info("Algorithm arm stats",
+					zap.String("arm", arm.Arm),
+					zap.String("algorithm", arm.Algorithm),
+					zap.Int64("matches", arm.Stats.SuccessfulMatches),
+					zap.Float64("avg_quality", arm.Stats.AverageMatchQuality),
+				)
+			}
 		}
 	}
 }
@@ -279,3 +793,21 @@ func (e *MatchingEngine) cleanupExpiredMatches() {
 		}
 	}
 }
+
+func (e *MatchingEngine) expireReadyChecks() {
+	defer e.wg.Done()
+	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := e.readyChecks.ExpireTimedOut(e.ctx); err != nil {
+				e.logger.GetLogger().Error("Failed to expire ready checks",
+					zap.Error(err),
+				)
+			}
+		}
+	}
+}
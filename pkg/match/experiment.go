@@ -0,0 +1,96 @@
+package match
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+
+	"github.com/mangooer/gamehub-arena/internal/config"
+	"github.com/mangooer/gamehub-arena/pkg/algorithm"
+)
+
+// 实验分组
+const (
+	ArmControl    = "control"
+	ArmChallenger = "challenger"
+)
+
+// 分桶粒度，万分之一
+const experimentBuckets = 10000
+
+var ErrInvalidExperiment = errors.New("invalid experiment")
+
+// 一组生效中的匹配算法：对照组算法以及可选的挑战者算法
+// 切换时整体替换，不在原对象上修改
+type algorithmSet struct {
+	control    algorithm.MatchingAlgorithm
+	challenger algorithm.MatchingAlgorithm
+	percent    float64
+	salt       string
+}
+
+// 玩家所在的实验分组，预组队按队伍ID分桶以保证队员同组
+func (s *algorithmSet) armOf(player *algorithm.Player) string {
+	if s.challenger == nil || s.percent <= 0 {
+		return ArmControl
+	}
+	key := fmt.Sprintf("%s:%d", s.salt, player.ID)
+	if player.PartyID != "" {
+		key = fmt.Sprintf("%s:party:%s", s.salt, player.PartyID)
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	if float64(h.Sum32()%experimentBuckets) < s.percent*experimentBuckets/100 {
+		return ArmChallenger
+	}
+	return ArmControl
+}
+
+func (s *algorithmSet) algorithmFor(arm string) algorithm.MatchingAlgorithm {
+	if arm == ArmChallenger {
+		return s.challenger
+	}
+	return s.control
+}
+
+// 只保留与玩家同组的候选玩家，保证每场对局完全由同一算法产生
+func (s *algorithmSet) sameArm(arm string, candidates []*algorithm.Player) []*algorithm.Player {
+	if s.challenger == nil {
+		return candidates
+	}
+	filtered := make([]*algorithm.Player, 0, len(candidates))
+	for _, candidate := range candidates {
+		if s.armOf(candidate) == arm {
+			filtered = append(filtered, candidate)
+		}
+	}
+	return filtered
+}
+
+// 根据实验配置创建挑战者算法，未启用时返回 nil
+func newChallenger(factory *algorithm.AlgorithmFactory, control algorithm.MatchingAlgorithm, cfg config.ExperimentConfig) (algorithm.MatchingAlgorithm, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	if cfg.TrafficPercent <= 0 || cfg.TrafficPercent >= 100 {
+		return nil, fmt.Errorf("%w: traffic percent %.2f out of range (0, 100)", ErrInvalidExperiment, cfg.TrafficPercent)
+	}
+	challenger, err := factory.GetAlgorithm(cfg.Challenger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get challenger algorithm: %w", err)
+	}
+	// 工厂按名称复用实例，同名算法会共享统计数据
+	if challenger == control {
+		return nil, fmt.Errorf("%w: challenger %s is the control algorithm", ErrInvalidExperiment, cfg.Challenger)
+	}
+	return challenger, nil
+}
+
+// 实验分组的匹配统计
+type ArmStats struct {
+	Arm            string                    `json:"arm"`
+	Algorithm      string                    `json:"algorithm"`
+	Version        string                    `json:"version"`
+	TrafficPercent float64                   `json:"traffic_percent"`
+	Stats          *algorithm.AlgorithmStats `json:"stats"`
+}
@@ -0,0 +1,103 @@
+package match
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/mangooer/gamehub-arena/internal/models"
+	"github.com/mangooer/gamehub-arena/internal/repository"
+	"github.com/mangooer/gamehub-arena/pkg/algorithm"
+)
+
+// 基于 MatchRepository 的对局持久化
+type repositoryMatchStore struct {
+	repo *repository.MatchRepository
+}
+
+func NewMatchStore(repo *repository.MatchRepository) MatchStore {
+	return &repositoryMatchStore{repo: repo}
+}
+
+func (s *repositoryMatchStore) SaveMatch(ctx context.Context, result *algorithm.MatchResult, roomID uint64) error {
+	return s.repo.CreateMatch(NewMatchRecord(result, roomID))
+}
+
+// NewMatchRecord 将匹配结果转换为 match_records / match_players 记录
+// 排队时间计算到成局时刻，不包含就绪确认的耗时
+func NewMatchRecord(result *algorithm.MatchResult, roomID uint64) *models.MatchRecord {
+	matchedAt := result.CreatedAt
+	if matchedAt.IsZero() {
+		matchedAt = time.Now()
+	}
+
+	record := &models.MatchRecord{
+		MatchID:          result.MatchID,
+		GameMode:         result.Players[0].GameMode,
+		PlayerCount:      len(result.Players),
+		AvgRank:          averageRank(result.Players),
+		MatchQuality:     math.Round(math.Max(0, math.Min(1, result.Quality))*100) / 100,
+		AlgorithmVersion: algorithmVersion(result),
+		Status:           models.MatchStatusCompleted,
+		CreatedAt:        matchedAt,
+		Players:          make([]models.MatchPlayer, 0, len(result.Players)),
+	}
+	if roomID > 0 {
+		record.RoomID = &roomID
+	}
+	if result.Prediction != nil {
+		record.Fairness = &result.Prediction.Fairness
+	}
+
+	var totalWait int
+	for _, p := range result.Players {
+		wait := int(matchedAt.Sub(p.QueueTime).Seconds())
+		if p.QueueTime.IsZero() || wait < 0 {
+			wait = 0
+		}
+		totalWait += wait
+		player := models.MatchPlayer{
+			UserID:         p.ID,
+			QueueTime:      wait,
+			Rank:           p.Rank,
+			WinRate:        p.WinRate,
+			TeamAssignment: result.TeamOf(p.ID),
+			CreatedAt:      matchedAt,
+		}
+		if result.Prediction != nil {
+			if prediction, ok := result.Prediction.ForPlayer(p.ID); ok {
+				player.WinProbability = &prediction.WinProbability
+				player.ExpectedGain = &prediction.MMRGain
+				player.ExpectedLoss = &prediction.MMRLoss
+			}
+		}
+		record.Players = append(record.Players, player)
+	}
+	record.AvgWaitTime = totalWait / len(result.Players)
+	return record
+}
+
+// 对局平均段位：MMR最接近对局平均MMR的玩家的段位
+func averageRank(players []*algorithm.Player) string {
+	var sum float64
+	for _, p := range players {
+		sum += p.MMR
+	}
+	avg := sum / float64(len(players))
+
+	rank, best := "", math.Inf(1)
+	for _, p := range players {
+		if diff := math.Abs(p.MMR - avg); diff < best && p.Rank != "" {
+			rank, best = p.Rank, diff
+		}
+	}
+	return rank
+}
+
+// 结果中记录的算法版本，旧结果退回算法名称
+func algorithmVersion(result *algorithm.MatchResult) string {
+	if version, ok := result.Metadata["algorithm_version"].(string); ok && version != "" {
+		return version
+	}
+	return result.Algorithm
+}
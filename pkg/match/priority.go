@@ -0,0 +1,147 @@
+package match
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/mangooer/gamehub-arena/internal/cache"
+	"github.com/mangooer/gamehub-arena/internal/repository"
+	"github.com/mangooer/gamehub-arena/pkg/algorithm"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// 近期弃赛记录，用于判断玩家是否进入低优先级队列
+type AbandonHistory interface {
+	CountAbandons(ctx context.Context, userID uint64, since time.Time) (int64, error)
+}
+
+// 基于 MatchRepository 的弃赛记录
+type repositoryAbandonHistory struct {
+	repo *repository.MatchRepository
+}
+
+func NewAbandonHistory(repo *repository.MatchRepository) AbandonHistory {
+	return &repositoryAbandonHistory{repo: repo}
+}
+
+func (h *repositoryAbandonHistory) CountAbandons(ctx context.Context, userID uint64, since time.Time) (int64, error) {
+	return h.repo.CountAbandons(userID, since)
+}
+
+// 设置入队玩家的优先级，忽略客户端传入的值
+// 惩罚期内的弃赛达到阈值时进入低优先级队列，否则继承上次排队超时留下的评分
+func (q *QueueManager) assignPriority(ctx context.Context, player *algorithm.Player, now time.Time) error {
+	player.PriorityScore, player.LowPriority = 0, false
+	if !q.config.Priority.Enabled {
+		return nil
+	}
+
+	lowPriority, err := q.inPenalty(ctx, player.ID, now)
+	if err != nil {
+		return err
+	}
+	if lowPriority {
+		player.LowPriority = true
+		return nil
+	}
+
+	value, err := q.cache.Get(ctx, cache.MatchPriorityKey(player.ID))
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil
+		}
+		return fmt.Errorf("failed to get queue priority: %w", err)
+	}
+	score, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil
+	}
+	player.PriorityScore = q.clampPriority(score)
+	return nil
+}
+
+// 玩家惩罚期内的弃赛次数是否达到阈值
+func (q *QueueManager) inPenalty(ctx context.Context, userID uint64, now time.Time) (bool, error) {
+	lp := q.config.Priority.LowPriority
+	if !lp.Enabled || q.abandons == nil || lp.PenaltyPeriod <= 0 {
+		return false, nil
+	}
+	since := now.Add(-time.Duration(lp.PenaltyPeriod) * time.Second)
+	count, err := q.abandons.CountAbandons(ctx, userID, since)
+	if err != nil {
+		return false, fmt.Errorf("failed to count abandons: %w", err)
+	}
+	return count >= int64(max(1, lp.AbandonThreshold)), nil
+}
+
+// 入队成功后删除已继承的评分，失败只记录日志
+func (q *QueueManager) consumePriority(ctx context.Context, players ...*algorithm.Player) {
+	keys := make([]string, 0, len(players))
+	for _, p := range players {
+		if p.PriorityScore > 0 {
+			keys = append(keys, cache.MatchPriorityKey(p.ID))
+		}
+	}
+	if len(keys) == 0 {
+		return
+	}
+	if err := q.cache.Del(ctx, keys...); err != nil {
+		q.logger.GetLogger().Warn("failed to consume queue priority", zap.Error(err))
+	}
+}
+
+// 提高单元内所有玩家的评分，放回队列前调用
+func (q *QueueManager) raisePriority(entry *QueueEntry, points float64) {
+	if !q.config.Priority.Enabled || points <= 0 {
+		return
+	}
+	for _, member := range entry.Members() {
+		member.PriorityScore = q.clampPriority(member.PriorityScore + points)
+	}
+}
+
+// 排队超时未成局的玩家按等待时长保留评分，在 carry_over 内重新排队时继承
+// 低优先级队列中的玩家不累积评分
+func (q *QueueManager) carryOverPriority(ctx context.Context, entry *QueueEntry, now time.Time) error {
+	cfg := q.config.Priority
+	if !cfg.Enabled || cfg.CarryOver <= 0 {
+		return nil
+	}
+	ttl := time.Duration(cfg.CarryOver) * time.Second
+	for _, member := range entry.Members() {
+		if member.LowPriority {
+			continue
+		}
+		waited := now.Sub(member.QueueTime).Minutes()
+		score := q.clampPriority(member.PriorityScore + waited*cfg.WaitBonusPerMinute)
+		if score <= 0 {
+			continue
+		}
+		if err := q.cache.Set(ctx, cache.MatchPriorityKey(member.ID), strconv.FormatFloat(score, 'f', 2, 64), ttl); err != nil {
+			return fmt.Errorf("failed to save queue priority: %w", err)
+		}
+	}
+	return nil
+}
+
+// 评分限制在 [0, max_score]，保留两位小数与 match_queue.priority_score 一致
+func (q *QueueManager) clampPriority(score float64) float64 {
+	score = math.Max(0, score)
+	if limit := q.config.Priority.MaxScore; limit > 0 {
+		score = math.Min(limit, score)
+	}
+	return math.Round(score*100) / 100
+}
+
+// 按优先级评分从高到低排序，同分保持原有顺序
+func sortByPriority(players []*algorithm.Player) {
+	sort.SliceStable(players, func(i, j int) bool {
+		return players[i].PriorityScore > players[j].PriorityScore
+	})
+}
@@ -0,0 +1,907 @@
+package match
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/mangooer/gamehub-arena/internal/cache"
+	"github.com/mangooer/gamehub-arena/internal/config"
+	"github.com/mangooer/gamehub-arena/internal/logger"
+	"github.com/mangooer/gamehub-arena/pkg/algorithm"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrNotInQueue      = errors.New("player not in queue")
+	ErrAlreadyInQueue  = errors.New("player already in queue")
+	ErrInvalidGameMode = errors.New("invalid game mode")
+	ErrPlayerClaimed   = errors.New("player already claimed by another match")
+	ErrInvalidParty    = errors.New("invalid party")
+	ErrNotPartyLeader  = errors.New("only party leader can cancel party queue")
+	ErrInvalidRegion   = errors.New("invalid region")
+	ErrQueueCooldown   = errors.New("player is in queue cooldown")
+	ErrInvalidRole     = errors.New("invalid role")
+	ErrInReadyCheck    = errors.New("player is in a pending ready check")
+)
+
+// 组队单元在队列中的成员前缀，单人单元直接使用用户ID
+const partyMemberPrefix = "party:"
+
+// 读取整个匹配桶时每页的单元数
+const queuePageSize = 500
+
+// 原子地加入队列：任一玩家已在队列中则不做修改并返回其所在单元，否则返回空字符串
+// KEYS: mmr索引, 时间索引, 单元数据, 玩家索引
+// ARGV: 单元, MMR, 入队时间戳, 单元数据, 用户ID列表...
+const enqueueScript = `
+for i = 5, #ARGV do
+	local existing = redis.call('HGET', KEYS[4], ARGV[i])
+	if existing then
+		return existing
+	end
+end
+for i = 5, #ARGV do
+	redis.call('HSET', KEYS[4], ARGV[i], ARGV[1])
+end
+redis.call('HSET', KEYS[3], ARGV[1], ARGV[4])
+redis.call('ZADD', KEYS[2], ARGV[3], ARGV[1])
+redis.call('ZADD', KEYS[1], ARGV[2], ARGV[1])
+return ''
+`
+
+// 原子地认领一组单元：任一单元已不在队列中则不做任何修改并返回该单元，
+// 否则一次性从所有键中移除这些单元及其玩家，并返回单元数据。
+// 跨区域对局的单元来自不同区域，因此从所有区域的mmr索引中移除。
+// 带租约值时先校验匹配桶租约仍为该值，租约已被其他实例接管时返回 {-1}。
+// KEYS: 时间索引, 单元数据, 玩家索引, [桶租约], 各区域mmr索引...
+// ARGV: 租约值（不校验时为空）, 单元数量n, n个单元, 用户ID列表...
+const claimPlayersScript = `
+local first = 4
+if ARGV[1] ~= '' then
+	if redis.call('GET', KEYS[4]) ~= ARGV[1] then
+		return {-1}
+	end
+	first = 5
+end
+local n = tonumber(ARGV[2])
+for i = 3, n + 2 do
+	if not redis.call('ZSCORE', KEYS[1], ARGV[i]) then
+		return {0, ARGV[i]}
+	end
+end
+local result = {1}
+for i = 3, n + 2 do
+	result[#result + 1] = redis.call('HGET', KEYS[2], ARGV[i]) or ''
+	redis.call('ZREM', KEYS[1], ARGV[i])
+	redis.call('HDEL', KEYS[2], ARGV[i])
+	for k = first, #KEYS do
+		redis.call('ZREM', KEYS[k], ARGV[i])
+	end
+end
+for i = n + 3, #ARGV do
+	redis.call('HDEL', KEYS[3], ARGV[i])
+end
+return result
+`
+
+// 队列条目（一个排队单元），以JSON形式保存在 match:queue:{mode}:players 中
+// 单人排队时 Player 非空，组队排队时 Party 非空
+type QueueEntry struct {
+	Player     *algorithm.Player `json:"player,omitempty"`
+	Party      *algorithm.Party  `json:"party,omitempty"`
+	EnqueuedAt time.Time         `json:"enqueued_at"`
+}
+
+// Members 单元内的所有玩家
+func (e *QueueEntry) Members() []*algorithm.Player {
+	if e.Party != nil {
+		return e.Party.Members
+	}
+	return []*algorithm.Player{e.Player}
+}
+
+// QueueTime 单元的排队开始时间
+func (e *QueueEntry) QueueTime() time.Time {
+	return e.Members()[0].QueueTime
+}
+
+// 单元在队列中的MMR分数，组队使用综合MMR
+func (e *QueueEntry) score() float64 {
+	return e.Members()[0].EffectiveMMR()
+}
+
+func (e *QueueEntry) unitMember() string {
+	if e.Party != nil {
+		return partyMember(e.Party.ID)
+	}
+	return memberOf(e.Player.ID)
+}
+
+func (e *QueueEntry) memberIDs() []interface{} {
+	members := e.Members()
+	ids := make([]interface{}, 0, len(members))
+	for _, p := range members {
+		ids = append(ids, memberOf(p.ID))
+	}
+	return ids
+}
+
+// 队列状态，供客户端查询
+type QueueStatus struct {
+	UserID    uint64        `json:"user_id"`
+	GameMode  string        `json:"game_mode"`
+	Region    string        `json:"region"`
+	PartyID   string        `json:"party_id,omitempty"`
+	QueueTime time.Time     `json:"queue_time"`
+	WaitTime  time.Duration `json:"wait_time"`
+	QueueSize int64         `json:"queue_size"`
+
+	EstimatedWait time.Duration `json:"estimated_wait"` // 预计剩余等待时间，每次查询时刷新
+
+	SearchWindow SearchWindow `json:"search_window"` // 当前搜索窗口
+}
+
+// QueueManager 基于Redis有序集合的匹配队列
+//
+// 排队的基本单位是“单元”：单人玩家或一个预组队。每个游戏模式使用以下键：
+//   - match:queue:{mode}:region:{region}  每个区域一个，成员为单元，分数为MMR（组队为综合MMR），用于按MMR窗口取候选
+//   - match:queue:{mode}:time             成员为单元，分数为入队时间戳（低优先级单元加上额外等待），用于超时清理
+//   - match:queue:{mode}:players          单元 -> QueueEntry JSON
+//   - match:queue:{mode}:members          用户ID -> 所在单元
+//
+// 玩家索引是 (user_id, game_mode) 唯一性的来源，与 match_queue 表的唯一约束一致，
+// 因此同一玩家在同一模式下只会处于一个区域的队列中。
+//
+// 入队时为玩家设置优先级：近期弃赛的玩家进入低优先级队列，只能互相匹配且需额外等待；
+// 其余玩家继承上次排队超时留下的评分，被他人拒绝对局而放回队列时评分提高。
+type QueueManager struct {
+	cache     cache.CacheService
+	abandons  AbandonHistory
+	config    *config.MatchConfig
+	estimator *waitEstimator
+	logger    logger.Logger
+}
+
+// NewQueueManager 创建匹配队列，abandons 为空时不启用低优先级队列
+func NewQueueManager(cache cache.CacheService, abandons AbandonHistory, config *config.MatchConfig, logger logger.Logger) *QueueManager {
+	return &QueueManager{
+		cache:     cache,
+		abandons:  abandons,
+		config:    config,
+		estimator: newWaitEstimator(cache, config),
+		logger:    logger,
+	}
+}
+
+// Enqueue 单人加入匹配队列
+// 同一玩家在同一模式下重复入队是幂等的，返回已存在的条目且不会重置排队时间
+func (q *QueueManager) Enqueue(ctx context.Context, player *algorithm.Player) (*QueueEntry, error) {
+	if player == nil {
+		return nil, fmt.Errorf("player cannot be nil")
+	}
+	if !q.isValidGameMode(player.GameMode) {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidGameMode, player.GameMode)
+	}
+
+	player.Region = q.regionOf(player)
+	if !q.config.HasRegion(player.Region) {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidRegion, player.Region)
+	}
+	if err := q.validateRoles(player); err != nil {
+		return nil, err
+	}
+	if err := q.checkAvailable(ctx, player.ID); err != nil {
+		return nil, err
+	}
+
+	// 排队时间以服务端为准，放回队列的单元经 Requeue 保留原排队时间
+	now := time.Now()
+	player.QueueTime = now
+	if err := q.assignPriority(ctx, player, now); err != nil {
+		return nil, err
+	}
+	player.PartyID, player.PartySize, player.PartyMMR = "", 0, 0
+	entry := &QueueEntry{Player: player, EnqueuedAt: now}
+	if err := q.addEntry(ctx, player.GameMode, player.Region, entry, player.MMR); err != nil {
+		return nil, err
+	}
+	q.consumePriority(ctx, player)
+
+	q.logger.GetLogger().Info("Player enqueued",
+		zap.Uint64("user_id", player.ID),
+		zap.String("game_mode", player.GameMode),
+		zap.String("region", player.Region),
+		zap.Float64("mmr", player.MMR),
+		zap.Float64("priority_score", player.PriorityScore),
+		zap.Bool("low_priority", player.LowPriority),
+	)
+	return entry, nil
+}
+
+// EnqueueParty 预组队整体加入匹配队列
+// 队伍以综合MMR在队长所在区域排队，只会被放入能容纳全部成员的对局并分在同一队伍
+func (q *QueueManager) EnqueueParty(ctx context.Context, party *algorithm.Party) (*QueueEntry, error) {
+	if party == nil {
+		return nil, fmt.Errorf("%w: party cannot be nil", ErrInvalidParty)
+	}
+	if err := party.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidParty, err)
+	}
+	gameMode := party.Members[0].GameMode
+	if !q.isValidGameMode(gameMode) {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidGameMode, gameMode)
+	}
+	size := len(party.Members)
+	if size > q.config.Queue.MaxPartySize || size > q.config.TeamSize(gameMode) {
+		return nil, fmt.Errorf("%w: party size %d not allowed in %s", ErrInvalidParty, size, gameMode)
+	}
+
+	var region string
+	for _, member := range party.Members {
+		if member.ID == party.LeaderID {
+			region = q.regionOf(member)
+		}
+	}
+	if !q.config.HasRegion(region) {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidRegion, region)
+	}
+	memberIDs := make([]uint64, 0, size)
+	for _, member := range party.Members {
+		if err := q.validateRoles(member); err != nil {
+			return nil, err
+		}
+		memberIDs = append(memberIDs, member.ID)
+	}
+	if err := q.checkAvailable(ctx, memberIDs...); err != nil {
+		return nil, err
+	}
+
+	// 任一成员处于低优先级队列时全队进入低优先级队列
+	now := time.Now()
+	lowPriority := false
+	for _, member := range party.Members {
+		if err := q.assignPriority(ctx, member, now); err != nil {
+			return nil, err
+		}
+		lowPriority = lowPriority || member.LowPriority
+	}
+
+	partyMMR := algorithm.PartyMMR(party.Members, q.config.Queue.PartyMMRBonus)
+	for _, member := range party.Members {
+		member.QueueTime = now
+		member.PartyID = party.ID
+		member.PartySize = size
+		member.PartyMMR = partyMMR
+		member.Region = region
+		member.LowPriority = lowPriority
+	}
+	entry := &QueueEntry{Party: party, EnqueuedAt: now}
+	if err := q.addEntry(ctx, gameMode, region, entry, partyMMR); err != nil {
+		return nil, err
+	}
+	q.consumePriority(ctx, party.Members...)
+
+	q.logger.GetLogger().Info("Party enqueued",
+		zap.String("party_id", party.ID),
+		zap.Uint64("leader_id", party.LeaderID),
+		zap.Int("size", size),
+		zap.String("game_mode", gameMode),
+		zap.String("region", region),
+		zap.Float64("party_mmr", partyMMR),
+		zap.Bool("low_priority", lowPriority),
+	)
+	return entry, nil
+}
+
+// Requeue 将认领后未能成局的单元放回队列，保留原排队时间和入队信息
+func (q *QueueManager) Requeue(ctx context.Context, entry *QueueEntry) error {
+	leader := entry.Members()[0]
+	if err := q.addEntry(ctx, leader.GameMode, q.regionOf(leader), entry, entry.score()); err != nil {
+		return err
+	}
+
+	q.logger.GetLogger().Info("Unit requeued",
+		zap.String("unit", entry.unitMember()),
+		zap.Int("players", len(entry.Members())),
+		zap.String("game_mode", leader.GameMode),
+		zap.Time("queue_time", entry.QueueTime()),
+	)
+	return nil
+}
+
+// SetCooldown 禁止玩家在 duration 内重新排队
+func (q *QueueManager) SetCooldown(ctx context.Context, userID uint64, duration time.Duration) error {
+	if duration <= 0 {
+		return nil
+	}
+	if err := q.cache.Set(ctx, cache.MatchCooldownKey(userID), time.Now().Add(duration).Unix(), duration); err != nil {
+		return fmt.Errorf("failed to set queue cooldown: %w", err)
+	}
+	return nil
+}
+
+// Dequeue 将玩家所在单元移出队列（匹配成功等内部流程使用），玩家不在队列中时不报错
+func (q *QueueManager) Dequeue(ctx context.Context, gameMode string, userID uint64) error {
+	entry, err := q.getEntry(ctx, gameMode, userID)
+	if err != nil {
+		if errors.Is(err, ErrNotInQueue) {
+			return nil
+		}
+		return err
+	}
+	if err := q.removeEntries(ctx, gameMode, entry); err != nil && !errors.Is(err, ErrPlayerClaimed) {
+		return fmt.Errorf("failed to dequeue player: %w", err)
+	}
+	return nil
+}
+
+// ClaimMatch 原子地将匹配结果中的所有单元移出队列
+// 重叠的段位协程可能同时为同一玩家找到匹配，只有第一个认领成功的结果有效，
+// 其余结果返回 ErrPlayerClaimed，队列保持不变。
+// ctx 携带匹配桶租约时同时校验隔离令牌，租约已失效返回 ErrLeaseLost
+func (q *QueueManager) ClaimMatch(ctx context.Context, result *algorithm.MatchResult) ([]*QueueEntry, error) {
+	if result == nil || len(result.Players) == 0 {
+		return nil, fmt.Errorf("match result has no players")
+	}
+
+	gameMode := result.Players[0].GameMode
+	var units []string
+	partyCounts := make(map[string]int)
+	userIDs := make([]interface{}, 0, len(result.Players))
+	for _, player := range result.Players {
+		if player.GameMode != gameMode {
+			return nil, fmt.Errorf("%w: players from different game modes", ErrInvalidGameMode)
+		}
+		userIDs = append(userIDs, memberOf(player.ID))
+		if player.PartyID == "" {
+			units = append(units, memberOf(player.ID))
+			continue
+		}
+		if partyCounts[player.PartyID] == 0 {
+			units = append(units, partyMember(player.PartyID))
+		}
+		partyCounts[player.PartyID]++
+	}
+	// 组队必须整体进入对局
+	for _, player := range result.Players {
+		if player.PartyID != "" && partyCounts[player.PartyID] != player.PartySize {
+			return nil, fmt.Errorf("%w: party %s is incomplete in match", ErrInvalidParty, player.PartyID)
+		}
+	}
+
+	entries, err := q.claimUnits(ctx, gameMode, leaseFrom(ctx), units, userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	q.logger.GetLogger().Info("Match claimed",
+		zap.String("match_id", result.MatchID),
+		zap.String("game_mode", gameMode),
+		zap.Int("units", len(entries)),
+		zap.Int("players", len(result.Players)),
+	)
+	return entries, nil
+}
+
+// RecordMatch 记录对局中玩家的等待时间，用于预计等待时间
+// 只在对局确定进行（确认完成或补位成功）后调用，未成局又放回队列的玩家不计入，失败只记录日志
+func (q *QueueManager) RecordMatch(ctx context.Context, result *algorithm.MatchResult) {
+	if err := q.estimator.record(ctx, result, time.Now()); err != nil {
+		q.logger.GetLogger().Warn("failed to record match throughput",
+			zap.String("match_id", result.MatchID),
+			zap.Error(err),
+		)
+	}
+}
+
+// Cancel 玩家主动取消排队
+// 组队排队时只有队长可以取消，取消后全队出队
+func (q *QueueManager) Cancel(ctx context.Context, gameMode string, userID uint64) error {
+	entry, err := q.getEntry(ctx, gameMode, userID)
+	if err != nil {
+		return err
+	}
+	if entry.Party != nil && entry.Party.LeaderID != userID {
+		return ErrNotPartyLeader
+	}
+	if err := q.removeEntries(ctx, gameMode, entry); err != nil {
+		if errors.Is(err, ErrPlayerClaimed) {
+			// 取消与匹配认领并发，玩家已进入对局
+			return ErrNotInQueue
+		}
+		return fmt.Errorf("failed to cancel queue: %w", err)
+	}
+
+	fields := []zap.Field{
+		zap.Uint64("user_id", userID),
+		zap.String("game_mode", gameMode),
+	}
+	if entry.Party != nil {
+		fields = append(fields, zap.String("party_id", entry.Party.ID), zap.Int("size", len(entry.Party.Members)))
+	}
+	q.logger.GetLogger().Info("Player cancelled queue", fields...)
+	return nil
+}
+
+// GetQueueStatus 获取玩家排队状态
+func (q *QueueManager) GetQueueStatus(ctx context.Context, gameMode string, userID uint64) (*QueueStatus, error) {
+	entry, err := q.getEntry(ctx, gameMode, userID)
+	if err != nil {
+		return nil, err
+	}
+	region := entry.Members()[0].Region
+	size, err := q.cache.ZCard(ctx, cache.MatchQueueKey(gameMode, region))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get queue size: %w", err)
+	}
+	status := &QueueStatus{
+		UserID:    userID,
+		GameMode:  gameMode,
+		Region:    region,
+		QueueTime: entry.QueueTime(),
+		WaitTime:  time.Since(entry.QueueTime()),
+		QueueSize: size,
+	}
+	status.SearchWindow = q.SearchWindow(entry.Members()[0])
+	if status.EstimatedWait, err = q.estimator.estimate(ctx, entry.Members()[0]); err != nil {
+		return nil, err
+	}
+	if entry.Party != nil {
+		status.PartyID = entry.Party.ID
+	}
+	return status, nil
+}
+
+// GetCandidates 获取同模式、可以参与匹配、与玩家互相在对方搜索窗口内的候选玩家（不包含玩家自身，包含其队友）
+// 候选玩家来自玩家所在区域，排队超过 cross_region_wait 后同时包含相邻区域
+func (q *QueueManager) GetCandidates(ctx context.Context, player *algorithm.Player) ([]*algorithm.Player, error) {
+	now := time.Now()
+	window := q.SearchWindow(player)
+	mmr := player.EffectiveMMR()
+
+	var candidates []*algorithm.Player
+	for _, region := range q.searchRegions(player) {
+		players, err := q.getPlayersByScore(ctx, player.GameMode, region, mmr-window.MMRDelta, mmr+window.MMRDelta, q.config.Queue.CandidateLimit)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range players {
+			if p.ID == player.ID {
+				continue
+			}
+			teammate := player.PartyID != "" && p.PartyID == player.PartyID
+			if !teammate && (!Matchable(q.config, p, now) || !MutuallyAcceptable(player, window, p, q.SearchWindow(p))) {
+				continue
+			}
+			candidates = append(candidates, p)
+		}
+	}
+	return candidates, nil
+}
+
+// Compatible 两名玩家能否进入同一对局，与 GetCandidates 的条件一致：
+// 同一模式，双方都可以参与匹配，一方位于另一方的搜索区域内，且双方搜索窗口互相接受；同一预组队的队员总是可以
+func (q *QueueManager) Compatible(a, b *algorithm.Player) bool {
+	if a.GameMode != b.GameMode {
+		return false
+	}
+	if a.PartyID != "" && a.PartyID == b.PartyID {
+		return true
+	}
+	now := time.Now()
+	if !Matchable(q.config, a, now) || !Matchable(q.config, b, now) {
+		return false
+	}
+	if !slices.Contains(q.searchRegions(a), q.regionOf(b)) && !slices.Contains(q.searchRegions(b), q.regionOf(a)) {
+		return false
+	}
+	return MutuallyAcceptable(a, q.SearchWindow(a), b, q.SearchWindow(b))
+}
+
+// GetBackfillCandidates 获取可以补位该空位的排队玩家
+// 候选来自空位所在区域及其相邻区域，按模式最宽的搜索窗口取出后再按玩家当前窗口过滤
+func (q *QueueManager) GetBackfillCandidates(ctx context.Context, slot *BackfillSlot) ([]*algorithm.Player, error) {
+	widest := q.config.SearchWindow(slot.GameMode, time.Duration(math.MaxInt64)).MMRDelta
+	regions := append([]string{slot.Region}, q.config.Regions[slot.Region].Neighbors...)
+
+	var candidates []*algorithm.Player
+	for _, region := range regions {
+		if !q.config.HasRegion(region) {
+			continue
+		}
+		players, err := q.getPlayersByScore(ctx, slot.GameMode, region, slot.TeamAverageMMR-widest, slot.TeamAverageMMR+widest, q.config.Queue.CandidateLimit)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range players {
+			if slot.accepts(p, q.SearchWindow(p)) {
+				candidates = append(candidates, p)
+			}
+		}
+	}
+	return candidates, nil
+}
+
+// GetBucketPlayers 获取匹配桶内等待的所有玩家，不受 candidate_limit 限制
+// includeNeighbors 为真时同时包含相邻区域中排队已放宽到该区域的玩家，供整批求解使用
+func (q *QueueManager) GetBucketPlayers(ctx context.Context, bucket MatchBucket, includeNeighbors bool) ([]*algorithm.Player, error) {
+	players, err := q.getPlayersByScore(ctx, bucket.GameMode, bucket.Region, bucket.Rank.MinMMR, bucket.Rank.MaxMMR, 0)
+	if err != nil || !includeNeighbors {
+		return players, err
+	}
+	for _, region := range q.config.RegionNames() {
+		if region == bucket.Region || !slices.Contains(q.config.Regions[region].Neighbors, bucket.Region) {
+			continue
+		}
+		neighbors, err := q.getPlayersByScore(ctx, bucket.GameMode, region, bucket.Rank.MinMMR, bucket.Rank.MaxMMR, 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range neighbors {
+			if slices.Contains(q.searchRegions(p), bucket.Region) {
+				players = append(players, p)
+			}
+		}
+	}
+	return players, nil
+}
+
+// GetTotalQueueSize 获取所有模式的排队单元总数
+func (q *QueueManager) GetTotalQueueSize() int {
+	ctx := context.Background()
+	total := 0
+	for _, gameMode := range q.config.Queue.GameModes {
+		size, err := q.cache.ZCard(ctx, cache.MatchQueueTimeKey(gameMode))
+		if err != nil {
+			q.logger.GetLogger().Warn("failed to get queue size",
+				zap.String("game_mode", gameMode),
+				zap.Error(err),
+			)
+			continue
+		}
+		total += int(size)
+	}
+	return total
+}
+
+// CleanupExpiredPlayers 移除排队超过超时时间的单元，并为其玩家保留下次排队的优先级评分
+func (q *QueueManager) CleanupExpiredPlayers(ctx context.Context) error {
+	now := time.Now()
+	deadline := now.Add(-time.Duration(q.config.Queue.Timeout) * time.Second)
+	for _, gameMode := range q.config.Queue.GameModes {
+		members, err := q.cache.ZRangeByScore(ctx, cache.MatchQueueTimeKey(gameMode), &redis.ZRangeBy{
+			Min: "-inf",
+			Max: strconv.FormatInt(deadline.Unix(), 10),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to get expired players: %w", err)
+		}
+		if len(members) == 0 {
+			continue
+		}
+
+		entries, err := q.getEntries(ctx, gameMode, members)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if err := q.removeEntries(ctx, gameMode, entry); err != nil {
+				if errors.Is(err, ErrPlayerClaimed) {
+					continue
+				}
+				return fmt.Errorf("failed to remove expired player: %w", err)
+			}
+			if err := q.carryOverPriority(ctx, entry, now); err != nil {
+				q.logger.GetLogger().Warn("failed to carry over queue priority",
+					zap.String("unit", entry.unitMember()),
+					zap.Error(err),
+				)
+			}
+			q.logger.GetLogger().Info("Player queue timeout",
+				zap.String("unit", entry.unitMember()),
+				zap.Int("players", len(entry.Members())),
+				zap.String("game_mode", gameMode),
+			)
+		}
+	}
+	return nil
+}
+
+func (q *QueueManager) addEntry(ctx context.Context, gameMode, region string, entry *QueueEntry, mmr float64) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal queue entry: %w", err)
+	}
+
+	unit := entry.unitMember()
+	// 低优先级单元的超时从额外等待结束后开始计算
+	timeoutBase := entry.QueueTime().Add(extraWait(q.config, entry.Members()[0]))
+	args := append([]interface{}{
+		unit,
+		strconv.FormatFloat(mmr, 'f', -1, 64),
+		timeoutBase.Unix(),
+		data,
+	}, entry.memberIDs()...)
+	keys := []string{
+		cache.MatchQueueKey(gameMode, region),
+		cache.MatchQueueTimeKey(gameMode),
+		cache.MatchQueuePlayersKey(gameMode),
+		cache.MatchQueueMembersKey(gameMode),
+	}
+	reply, err := q.cache.Eval(ctx, enqueueScript, keys, args...)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue: %w", err)
+	}
+
+	existing, _ := reply.(string)
+	if existing == "" {
+		return nil
+	}
+	if existing != unit {
+		return fmt.Errorf("%w: queued as %s", ErrAlreadyInQueue, existing)
+	}
+	// 同一单元重复入队，保持原条目
+	stored, err := q.getUnit(ctx, gameMode, unit)
+	if err != nil {
+		return err
+	}
+	*entry = *stored
+	return nil
+}
+
+func (q *QueueManager) claimUnits(ctx context.Context, gameMode string, lease *BucketLease, units []string, userIDs []interface{}) ([]*QueueEntry, error) {
+	keys := []string{
+		cache.MatchQueueTimeKey(gameMode),
+		cache.MatchQueuePlayersKey(gameMode),
+		cache.MatchQueueMembersKey(gameMode),
+	}
+	args := make([]interface{}, 0, 2+len(units)+len(userIDs))
+	if lease != nil {
+		keys = append(keys, cache.MatchLeaseKey(lease.Bucket.ID()))
+		args = append(args, lease.value())
+	} else {
+		args = append(args, "")
+	}
+	for _, region := range q.config.RegionNames() {
+		keys = append(keys, cache.MatchQueueKey(gameMode, region))
+	}
+	args = append(args, len(units))
+	for _, unit := range units {
+		args = append(args, unit)
+	}
+	args = append(args, userIDs...)
+
+	reply, err := q.cache.Eval(ctx, claimPlayersScript, keys, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim players: %w", err)
+	}
+
+	values, ok := reply.([]interface{})
+	if !ok || len(values) == 0 {
+		return nil, fmt.Errorf("unexpected claim reply: %v", reply)
+	}
+	switch status, _ := values[0].(int64); status {
+	case -1:
+		return nil, fmt.Errorf("%w: %s token %d", ErrLeaseLost, lease.Bucket.ID(), lease.Token)
+	case 0:
+		return nil, fmt.Errorf("%w: %v", ErrPlayerClaimed, values[1:])
+	}
+
+	entries := make([]*QueueEntry, 0, len(values)-1)
+	for _, value := range values[1:] {
+		data, _ := value.(string)
+		var entry QueueEntry
+		if err := json.Unmarshal([]byte(data), &entry); err != nil {
+			q.logger.GetLogger().Warn("invalid claimed queue entry",
+				zap.String("game_mode", gameMode),
+				zap.Error(err),
+			)
+			continue
+		}
+		entries = append(entries, &entry)
+	}
+	return entries, nil
+}
+
+// 按MMR区间读取区域内的单元并展开为玩家，最多读取 limit 个单元；limit 为0时分页读完整个区间
+func (q *QueueManager) getPlayersByScore(ctx context.Context, gameMode, region string, minMMR, maxMMR float64, limit int) ([]*algorithm.Player, error) {
+	var players []*algorithm.Player
+	for offset := 0; ; {
+		count := queuePageSize
+		if limit > 0 {
+			count = min(count, limit-offset)
+		}
+		members, err := q.cache.ZRangeByScore(ctx, cache.MatchQueueKey(gameMode, region), &redis.ZRangeBy{
+			Min:    strconv.FormatFloat(minMMR, 'f', -1, 64),
+			Max:    strconv.FormatFloat(maxMMR, 'f', -1, 64),
+			Offset: int64(offset),
+			Count:  int64(count),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get queue members: %w", err)
+		}
+		if len(members) > 0 {
+			entries, err := q.getEntries(ctx, gameMode, members)
+			if err != nil {
+				return nil, err
+			}
+			for _, entry := range entries {
+				players = append(players, entry.Members()...)
+			}
+		}
+		offset += len(members)
+		if len(members) < count || (limit > 0 && offset >= limit) {
+			return players, nil
+		}
+	}
+}
+
+func (q *QueueManager) getEntries(ctx context.Context, gameMode string, units []string) ([]*QueueEntry, error) {
+	values, err := q.cache.HMGet(ctx, cache.MatchQueuePlayersKey(gameMode), units...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get queue entries: %w", err)
+	}
+
+	entries := make([]*QueueEntry, 0, len(values))
+	for i, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			// 索引存在但数据已被移除，视为正在出队
+			continue
+		}
+		var entry QueueEntry
+		if err := json.Unmarshal([]byte(data), &entry); err != nil {
+			q.logger.GetLogger().Warn("invalid queue entry",
+				zap.String("game_mode", gameMode),
+				zap.String("unit", units[i]),
+				zap.Error(err),
+			)
+			continue
+		}
+		entries = append(entries, &entry)
+	}
+	return entries, nil
+}
+
+// 获取玩家所在单元的条目
+func (q *QueueManager) getEntry(ctx context.Context, gameMode string, userID uint64) (*QueueEntry, error) {
+	unit, err := q.cache.HGet(ctx, cache.MatchQueueMembersKey(gameMode), memberOf(userID))
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrNotInQueue
+		}
+		return nil, fmt.Errorf("failed to get queue unit: %w", err)
+	}
+	return q.getUnit(ctx, gameMode, unit)
+}
+
+func (q *QueueManager) getUnit(ctx context.Context, gameMode string, unit string) (*QueueEntry, error) {
+	data, err := q.cache.HGet(ctx, cache.MatchQueuePlayersKey(gameMode), unit)
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrNotInQueue
+		}
+		return nil, fmt.Errorf("failed to get queue entry: %w", err)
+	}
+	var entry QueueEntry
+	if err := json.Unmarshal([]byte(data), &entry); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal queue entry: %w", err)
+	}
+	return &entry, nil
+}
+
+// 原子地移除单元及其玩家索引
+func (q *QueueManager) removeEntries(ctx context.Context, gameMode string, entries ...*QueueEntry) error {
+	units := make([]string, 0, len(entries))
+	var userIDs []interface{}
+	for _, entry := range entries {
+		units = append(units, entry.unitMember())
+		userIDs = append(userIDs, entry.memberIDs()...)
+	}
+	_, err := q.claimUnits(ctx, gameMode, nil, units, userIDs)
+	return err
+}
+
+// 角色偏好只能包含模式的角色和 fill，不分角色的模式忽略偏好
+func (q *QueueManager) validateRoles(player *algorithm.Player) error {
+	roles := q.config.Roles(player.GameMode)
+	if len(roles) == 0 {
+		player.Roles = nil
+		return nil
+	}
+	seen := make(map[string]bool, len(player.Roles))
+	for _, preferred := range player.Roles {
+		if seen[preferred] {
+			return fmt.Errorf("%w: duplicate role %s", ErrInvalidRole, preferred)
+		}
+		seen[preferred] = true
+		if preferred == algorithm.RoleFill || slices.Contains(roles, preferred) {
+			continue
+		}
+		return fmt.Errorf("%w: %s not in %s", ErrInvalidRole, preferred, player.GameMode)
+	}
+	return nil
+}
+
+// 任一玩家处于排队冷却中时拒绝入队
+func (q *QueueManager) checkCooldown(ctx context.Context, userIDs ...uint64) error {
+	keys := make([]string, 0, len(userIDs))
+	for _, id := range userIDs {
+		keys = append(keys, cache.MatchCooldownKey(id))
+	}
+	count, err := q.cache.Exists(ctx, keys...)
+	if err != nil {
+		return fmt.Errorf("failed to check queue cooldown: %w", err)
+	}
+	if count > 0 {
+		return ErrQueueCooldown
+	}
+	return nil
+}
+
+// 玩家可以排队：不在冷却中，也不在尚未结束的就绪确认中
+func (q *QueueManager) checkAvailable(ctx context.Context, userIDs ...uint64) error {
+	if err := q.checkCooldown(ctx, userIDs...); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(userIDs))
+	for _, id := range userIDs {
+		keys = append(keys, cache.MatchReadyPlayerKey(id))
+	}
+	count, err := q.cache.Exists(ctx, keys...)
+	if err != nil {
+		return fmt.Errorf("failed to check ready check: %w", err)
+	}
+	if count > 0 {
+		return ErrInReadyCheck
+	}
+	return nil
+}
+
+// 玩家所在区域，未上报时使用默认区域
+func (q *QueueManager) regionOf(player *algorithm.Player) string {
+	if player.Region == "" {
+		return q.config.Queue.DefaultRegion
+	}
+	return player.Region
+}
+
+// 为玩家搜索候选的区域：所在区域优先，排队足够久后加入相邻区域
+func (q *QueueManager) searchRegions(player *algorithm.Player) []string {
+	region := q.regionOf(player)
+	regions := []string{region}
+	wait := time.Duration(q.config.Queue.CrossRegionWait) * time.Second
+	if time.Since(player.QueueTime) < wait {
+		return regions
+	}
+	for _, neighbor := range q.config.Regions[region].Neighbors {
+		if q.config.HasRegion(neighbor) {
+			regions = append(regions, neighbor)
+		}
+	}
+	return regions
+}
+
+func (q *QueueManager) isValidGameMode(gameMode string) bool {
+	for _, mode := range q.config.Queue.GameModes {
+		if mode == gameMode {
+			return true
+		}
+	}
+	return false
+}
+
+func memberOf(userID uint64) string {
+	return strconv.FormatUint(userID, 10)
+}
+
+func partyMember(partyID string) string {
+	return partyMemberPrefix + partyID
+}
@@ -0,0 +1,412 @@
+package match
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mangooer/gamehub-arena/internal/cache"
+	"github.com/mangooer/gamehub-arena/internal/config"
+	"github.com/mangooer/gamehub-arena/internal/logger"
+	"github.com/mangooer/gamehub-arena/pkg/algorithm"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrReadyCheckNotFound = errors.New("ready check not found")
+	ErrNotInReadyCheck    = errors.New("player not in ready check")
+	ErrAlreadyResponded   = errors.New("player already responded")
+	ErrReadyCheckClosed   = errors.New("ready check already closed")
+	ErrReadyCheckExists   = errors.New("ready check already exists")
+)
+
+// 就绪确认状态
+const (
+	ReadyPending  = "pending"
+	ReadyAccepted = "accepted"
+	ReadyDeclined = "declined"
+	ReadyTimeout  = "timeout"
+)
+
+// 玩家响应在哈希中的字段前缀
+const readyUserPrefix = "u:"
+
+// 创建就绪确认并标记玩家正在确认中，同一对局ID的确认已存在时返回0，不覆盖其中的响应
+// KEYS: 确认状态, 截止时间索引, 玩家确认标记...
+// ARGV: 对局ID, 截止时间戳, 过期秒数, 确认数据, 玩家字段...
+const startReadyCheckScript = `
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'status', 'pending', 'data', ARGV[4])
+for i = 5, #ARGV do
+	redis.call('HSET', KEYS[1], ARGV[i], 'pending')
+end
+redis.call('EXPIRE', KEYS[1], ARGV[3])
+redis.call('ZADD', KEYS[2], ARGV[2], ARGV[1])
+for i = 3, #KEYS do
+	redis.call('SET', KEYS[i], ARGV[1], 'EX', ARGV[3])
+end
+return 1
+`
+
+// 记录玩家响应，返回确认的最新状态；只有把状态从 pending 改为终态的调用会得到终态
+// KEYS: 确认状态, 截止时间索引
+// ARGV: 玩家字段, 响应, 对局ID
+const respondReadyCheckScript = `
+local status = redis.call('HGET', KEYS[1], 'status')
+if not status then
+	return 'missing'
+end
+local current = redis.call('HGET', KEYS[1], ARGV[1])
+if not current then
+	return 'not_member'
+end
+if status ~= 'pending' then
+	return 'closed'
+end
+if current ~= 'pending' then
+	return 'responded'
+end
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+if ARGV[2] == 'declined' then
+	redis.call('HSET', KEYS[1], 'status', 'declined')
+	redis.call('ZREM', KEYS[2], ARGV[3])
+	return 'declined'
+end
+local fields = redis.call('HGETALL', KEYS[1])
+for i = 1, #fields, 2 do
+	if string.sub(fields[i], 1, 2) == 'u:' and fields[i + 1] ~= 'accepted' then
+		return 'pending'
+	end
+end
+redis.call('HSET', KEYS[1], 'status', 'accepted')
+redis.call('ZREM', KEYS[2], ARGV[3])
+return 'accepted'
+`
+
+// 将超时的确认标记为 timeout，已结束的确认只清理截止时间索引
+// KEYS: 确认状态, 截止时间索引
+// ARGV: 对局ID
+const expireReadyCheckScript = `
+redis.call('ZREM', KEYS[2], ARGV[1])
+local status = redis.call('HGET', KEYS[1], 'status')
+if status ~= 'pending' then
+	return 'closed'
+end
+redis.call('HSET', KEYS[1], 'status', 'timeout')
+return 'timeout'
+`
+
+// 对局持久化，写入 match_records / match_players
+type MatchStore interface {
+	SaveMatch(ctx context.Context, result *algorithm.MatchResult, roomID uint64) error
+}
+
+// 为确认完成的对局创建房间，返回房间ID
+type RoomAllocator interface {
+	CreateMatchRoom(ctx context.Context, result *algorithm.MatchResult) (uint64, error)
+}
+
+// 一次就绪确认
+type ReadyCheck struct {
+	MatchID   string                 `json:"match_id"`
+	GameMode  string                 `json:"game_mode"`
+	Result    *algorithm.MatchResult `json:"result"`
+	Entries   []*QueueEntry          `json:"entries"` // 认领时的队列条目，用于放回队列
+	Deadline  time.Time              `json:"deadline"`
+	Status    string                 `json:"status"`
+	Responses map[uint64]string      `json:"responses"`
+}
+
+// ReadyCheckManager 对局就绪确认
+//
+// 匹配成功后所有玩家需在超时前确认。全部确认后创建房间并持久化对局；
+// 任一玩家拒绝或超时未确认时，全员确认的单元以原排队时间和提高的优先级放回队列，
+// 拒绝或未确认的玩家进入排队冷却。状态保存在 match:ready:{match_id}，
+// 结束状态只会被一次调用写入，因此多个实例并发处理同一确认是安全的。
+type ReadyCheckManager struct {
+	cache  cache.CacheService
+	queue  *QueueManager
+	store  MatchStore
+	rooms  RoomAllocator
+	config *config.MatchConfig
+	logger logger.Logger
+}
+
+func NewReadyCheckManager(cache cache.CacheService, queue *QueueManager, store MatchStore, rooms RoomAllocator, config *config.MatchConfig, logger logger.Logger) *ReadyCheckManager {
+	return &ReadyCheckManager{
+		cache:  cache,
+		queue:  queue,
+		store:  store,
+		rooms:  rooms,
+		config: config,
+		logger: logger,
+	}
+}
+
+// Start 为已认领的对局发起就绪确认
+func (r *ReadyCheckManager) Start(ctx context.Context, result *algorithm.MatchResult, entries []*QueueEntry) (*ReadyCheck, error) {
+	timeout := time.Duration(r.config.ReadyCheck.Timeout) * time.Second
+	check := &ReadyCheck{
+		MatchID:   result.MatchID,
+		GameMode:  result.Players[0].GameMode,
+		Result:    result,
+		Entries:   entries,
+		Deadline:  time.Now().Add(timeout),
+		Status:    ReadyPending,
+		Responses: make(map[uint64]string, len(result.Players)),
+	}
+	data, err := json.Marshal(check)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal ready check: %w", err)
+	}
+
+	// 状态在截止后保留一段时间，供客户端查询结果
+	// 玩家确认标记与状态同时过期，结束时提前清除
+	args := []interface{}{check.MatchID, check.Deadline.Unix(), int((2*timeout + time.Minute).Seconds()), data}
+	keys := r.keys(check.MatchID)
+	for _, p := range result.Players {
+		check.Responses[p.ID] = ReadyPending
+		args = append(args, readyField(p.ID))
+		keys = append(keys, cache.MatchReadyPlayerKey(p.ID))
+	}
+	reply, err := r.cache.Eval(ctx, startReadyCheckScript, keys, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start ready check: %w", err)
+	}
+	if created, _ := reply.(int64); created == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrReadyCheckExists, check.MatchID)
+	}
+
+	r.logger.GetLogger().Info("Ready check started",
+		zap.String("match_id", check.MatchID),
+		zap.Int("players", len(result.Players)),
+		zap.Time("deadline", check.Deadline),
+	)
+	return check, nil
+}
+
+// Accept 玩家确认对局，最后一名玩家确认后创建房间
+func (r *ReadyCheckManager) Accept(ctx context.Context, matchID string, userID uint64) error {
+	return r.respond(ctx, matchID, userID, ReadyAccepted)
+}
+
+// Decline 玩家拒绝对局，确认立即失败
+func (r *ReadyCheckManager) Decline(ctx context.Context, matchID string, userID uint64) error {
+	return r.respond(ctx, matchID, userID, ReadyDeclined)
+}
+
+// GetReadyCheck 获取就绪确认及各玩家的响应
+func (r *ReadyCheckManager) GetReadyCheck(ctx context.Context, matchID string) (*ReadyCheck, error) {
+	fields, err := r.cache.HGetAll(ctx, cache.MatchReadyKey(matchID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ready check: %w", err)
+	}
+	data, ok := fields["data"]
+	if !ok {
+		return nil, ErrReadyCheckNotFound
+	}
+
+	var check ReadyCheck
+	if err := json.Unmarshal([]byte(data), &check); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal ready check: %w", err)
+	}
+	check.Status = fields["status"]
+	for field, response := range fields {
+		if !strings.HasPrefix(field, readyUserPrefix) {
+			continue
+		}
+		userID, err := strconv.ParseUint(strings.TrimPrefix(field, readyUserPrefix), 10, 64)
+		if err != nil {
+			continue
+		}
+		check.Responses[userID] = response
+	}
+	return &check, nil
+}
+
+// ExpireTimedOut 结束所有已超时的确认
+func (r *ReadyCheckManager) ExpireTimedOut(ctx context.Context) error {
+	matchIDs, err := r.cache.ZRangeByScore(ctx, cache.MatchReadyTimersKey(), &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(time.Now().Unix(), 10),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get expired ready checks: %w", err)
+	}
+
+	for _, matchID := range matchIDs {
+		reply, err := r.cache.Eval(ctx, expireReadyCheckScript, r.keys(matchID), matchID)
+		if err != nil {
+			return fmt.Errorf("failed to expire ready check: %w", err)
+		}
+		if status, _ := reply.(string); status == ReadyTimeout {
+			r.finish(ctx, matchID)
+		}
+	}
+	return nil
+}
+
+func (r *ReadyCheckManager) respond(ctx context.Context, matchID string, userID uint64, response string) error {
+	reply, err := r.cache.Eval(ctx, respondReadyCheckScript, r.keys(matchID), readyField(userID), response, matchID)
+	if err != nil {
+		return fmt.Errorf("failed to respond ready check: %w", err)
+	}
+
+	status, _ := reply.(string)
+	switch status {
+	case "missing":
+		return ErrReadyCheckNotFound
+	case "not_member":
+		return ErrNotInReadyCheck
+	case "closed":
+		return ErrReadyCheckClosed
+	case "responded":
+		return ErrAlreadyResponded
+	}
+
+	r.logger.GetLogger().Info("Ready check response",
+		zap.String("match_id", matchID),
+		zap.Uint64("user_id", userID),
+		zap.String("response", response),
+	)
+	if status == ReadyAccepted || status == ReadyDeclined {
+		r.finish(ctx, matchID)
+	}
+	return nil
+}
+
+// 处理已结束的确认，只由写入结束状态的调用执行一次
+func (r *ReadyCheckManager) finish(ctx context.Context, matchID string) {
+	check, err := r.GetReadyCheck(ctx, matchID)
+	if err != nil {
+		r.logger.GetLogger().Error("failed to load finished ready check",
+			zap.String("match_id", matchID),
+			zap.Error(err),
+		)
+		return
+	}
+
+	if check.Status == ReadyAccepted {
+		if err := r.confirm(ctx, check); err != nil {
+			r.logger.GetLogger().Error("failed to confirm match",
+				zap.String("match_id", matchID),
+				zap.Error(err),
+			)
+			// 房间未创建，所有玩家都已确认，全部放回队列
+			r.clearPlayers(ctx, check)
+			r.requeue(ctx, check, 0)
+		}
+		return
+	}
+	r.fail(ctx, check)
+}
+
+// 全员确认：创建房间并持久化对局
+func (r *ReadyCheckManager) confirm(ctx context.Context, check *ReadyCheck) error {
+	if r.rooms == nil {
+		return fmt.Errorf("no room allocator configured")
+	}
+	roomID, err := r.rooms.CreateMatchRoom(ctx, check.Result)
+	if err != nil {
+		return fmt.Errorf("failed to create room: %w", err)
+	}
+	r.queue.RecordMatch(ctx, check.Result)
+	r.clearPlayers(ctx, check)
+
+	if r.store != nil {
+		if err := r.store.SaveMatch(ctx, check.Result, roomID); err != nil {
+			// 房间已创建，对局继续进行，只记录错误
+			r.logger.GetLogger().Error("failed to save match",
+				zap.String("match_id", check.MatchID),
+				zap.Uint64("room_id", roomID),
+				zap.Error(err),
+			)
+		}
+	}
+
+	r.logger.GetLogger().Info("Match confirmed",
+		zap.String("match_id", check.MatchID),
+		zap.Uint64("room_id", roomID),
+		zap.Int("players", len(check.Result.Players)),
+	)
+	return nil
+}
+
+// 拒绝或超时：未确认的玩家进入冷却，其余单元提高优先级后放回队列
+func (r *ReadyCheckManager) fail(ctx context.Context, check *ReadyCheck) {
+	cooldown := time.Duration(r.config.ReadyCheck.DeclineCooldown) * time.Second
+	var decliners []uint64
+	for userID, response := range check.Responses {
+		if response == ReadyAccepted {
+			continue
+		}
+		decliners = append(decliners, userID)
+		if err := r.queue.SetCooldown(ctx, userID, cooldown); err != nil {
+			r.logger.GetLogger().Error("failed to set queue cooldown",
+				zap.Uint64("user_id", userID),
+				zap.Error(err),
+			)
+		}
+	}
+	r.clearPlayers(ctx, check)
+	r.requeue(ctx, check, r.config.Priority.DodgeBonus)
+
+	r.logger.GetLogger().Info("Ready check failed",
+		zap.String("match_id", check.MatchID),
+		zap.String("status", check.Status),
+		zap.Uint64s("decliners", decliners),
+	)
+}
+
+// 将所有成员均已确认的单元以提高 bonus 后的优先级放回队列；有成员拒绝的组队不放回，由队长重新排队
+func (r *ReadyCheckManager) requeue(ctx context.Context, check *ReadyCheck, bonus float64) {
+	for _, entry := range check.Entries {
+		accepted := true
+		for _, member := range entry.Members() {
+			if check.Responses[member.ID] != ReadyAccepted {
+				accepted = false
+				break
+			}
+		}
+		if !accepted {
+			continue
+		}
+		r.queue.raisePriority(entry, bonus)
+		if err := r.queue.Requeue(ctx, entry); err != nil {
+			r.logger.GetLogger().Error("failed to requeue unit",
+				zap.String("match_id", check.MatchID),
+				zap.String("unit", entry.unitMember()),
+				zap.Error(err),
+			)
+		}
+	}
+}
+
+// 清除玩家的确认标记，玩家可以重新排队；需在放回队列前调用，避免清除放回后新对局的标记
+func (r *ReadyCheckManager) clearPlayers(ctx context.Context, check *ReadyCheck) {
+	keys := make([]string, 0, len(check.Result.Players))
+	for _, p := range check.Result.Players {
+		keys = append(keys, cache.MatchReadyPlayerKey(p.ID))
+	}
+	if err := r.cache.Del(ctx, keys...); err != nil {
+		r.logger.GetLogger().Warn("failed to clear ready check players",
+			zap.String("match_id", check.MatchID),
+			zap.Error(err),
+		)
+	}
+}
+
+func (r *ReadyCheckManager) keys(matchID string) []string {
+	return []string{cache.MatchReadyKey(matchID), cache.MatchReadyTimersKey()}
+}
+
+func readyField(userID uint64) string {
+	return readyUserPrefix + strconv.FormatUint(userID, 10)
+}
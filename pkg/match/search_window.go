@@ -0,0 +1,81 @@
+package match
+
+import (
+	"math"
+	"time"
+
+	"github.com/mangooer/gamehub-arena/internal/config"
+	"github.com/mangooer/gamehub-arena/pkg/algorithm"
+)
+
+// 玩家当前的搜索窗口，随排队时间按模式配置的阶梯扩大
+type SearchWindow struct {
+	MMRDelta   float64 `json:"mmr_delta"`   // 可接受的MMR差（±）
+	LevelDelta int     `json:"level_delta"` // 可接受的等级差，0为不限制
+	MaxPing    int     `json:"max_ping"`    // 可接受的对局延迟上限，0为不限制
+}
+
+// SearchWindow 获取玩家当前的搜索窗口
+func (q *QueueManager) SearchWindow(player *algorithm.Player) SearchWindow {
+	return WindowAt(q.config, player, time.Now())
+}
+
+// WindowAt 玩家在 now 时刻的搜索窗口，模拟器使用模拟时钟调用
+// 低优先级玩家的窗口从额外等待结束后才开始放宽
+func WindowAt(cfg *config.MatchConfig, player *algorithm.Player, now time.Time) SearchWindow {
+	waited := max(0, now.Sub(player.QueueTime)-extraWait(cfg, player))
+	step := cfg.SearchWindow(player.GameMode, waited)
+	return SearchWindow{
+		MMRDelta:   step.MMRDelta,
+		LevelDelta: step.LevelDelta,
+		MaxPing:    step.MaxPing,
+	}
+}
+
+// Matchable 玩家在 now 时刻能否参与匹配，低优先级玩家需先等待 extra_wait
+func Matchable(cfg *config.MatchConfig, player *algorithm.Player, now time.Time) bool {
+	return now.Sub(player.QueueTime) >= extraWait(cfg, player)
+}
+
+// 低优先级玩家入队后的额外等待时间
+func extraWait(cfg *config.MatchConfig, player *algorithm.Player) time.Duration {
+	if !player.LowPriority {
+		return 0
+	}
+	return time.Duration(cfg.Priority.LowPriority.ExtraWait) * time.Second
+}
+
+// MutuallyAcceptable 双方互相接受：处于同一优先级队列，MMR、等级差和共同机房延迟都在两人窗口中较小的一个之内
+func MutuallyAcceptable(p1 *algorithm.Player, w1 SearchWindow, p2 *algorithm.Player, w2 SearchWindow) bool {
+	if p1.LowPriority != p2.LowPriority {
+		return false
+	}
+	if math.Abs(p1.EffectiveMMR()-p2.EffectiveMMR()) > math.Min(w1.MMRDelta, w2.MMRDelta) {
+		return false
+	}
+	if limit := TighterLimit(w1.LevelDelta, w2.LevelDelta); limit > 0 && abs(p1.Level-p2.Level) > limit {
+		return false
+	}
+	if limit := TighterLimit(w1.MaxPing, w2.MaxPing); limit > 0 && algorithm.PairPing(p1, p2) > limit {
+		return false
+	}
+	return true
+}
+
+// TighterLimit 两个限制中较严格的一个，0表示不限制
+func TighterLimit(a, b int) int {
+	if a <= 0 {
+		return b
+	}
+	if b <= 0 {
+		return a
+	}
+	return min(a, b)
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,141 @@
+package match
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mangooer/gamehub-arena/internal/config"
+	"github.com/mangooer/gamehub-arena/pkg/algorithm"
+)
+
+func newTestWindowConfig() *config.MatchConfig {
+	return &config.MatchConfig{
+		Queue: config.QueueConfig{MMRWindow: 200, MaxPing: 150},
+		Modes: map[string]config.ModeConfig{
+			"ranked": {SearchWindow: []config.SearchWindowStep{
+				{After: 0, MMRDelta: 50, LevelDelta: 5, MaxPing: 60},
+				{After: 30, MMRDelta: 100, LevelDelta: 10, MaxPing: 80},
+				{After: 90, MMRDelta: 300, MaxPing: 120},
+			}},
+		},
+		Priority: config.PriorityConfig{LowPriority: config.LowPriorityConfig{ExtraWait: 60}},
+	}
+}
+
+func TestWindowAt(t *testing.T) {
+	cfg := newTestWindowConfig()
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		gameMode    string
+		waited      time.Duration
+		lowPriority bool
+		want        SearchWindow
+	}{
+		{name: "刚入队使用第一级", gameMode: "ranked", waited: 0, want: SearchWindow{MMRDelta: 50, LevelDelta: 5, MaxPing: 60}},
+		{name: "未到下一级", gameMode: "ranked", waited: 29 * time.Second, want: SearchWindow{MMRDelta: 50, LevelDelta: 5, MaxPing: 60}},
+		{name: "恰好到达下一级", gameMode: "ranked", waited: 30 * time.Second, want: SearchWindow{MMRDelta: 100, LevelDelta: 10, MaxPing: 80}},
+		{name: "超过最后一级", gameMode: "ranked", waited: 10 * time.Minute, want: SearchWindow{MMRDelta: 300, MaxPing: 120}},
+		{name: "未配置阶梯的模式使用队列窗口", gameMode: "casual", waited: 10 * time.Minute, want: SearchWindow{MMRDelta: 200, MaxPing: 150}},
+		{name: "低优先级额外等待期间不放宽", gameMode: "ranked", waited: 80 * time.Second, lowPriority: true, want: SearchWindow{MMRDelta: 50, LevelDelta: 5, MaxPing: 60}},
+		{name: "低优先级扣除额外等待后放宽", gameMode: "ranked", waited: 95 * time.Second, lowPriority: true, want: SearchWindow{MMRDelta: 100, LevelDelta: 10, MaxPing: 80}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			player := &algorithm.Player{GameMode: tt.gameMode, QueueTime: now.Add(-tt.waited), LowPriority: tt.lowPriority}
+			if got := WindowAt(cfg, player, now); got != tt.want {
+				t.Errorf("WindowAt() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchable(t *testing.T) {
+	cfg := newTestWindowConfig()
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		waited      time.Duration
+		lowPriority bool
+		want        bool
+	}{
+		{name: "普通玩家立即参与匹配", waited: 0, want: true},
+		{name: "低优先级玩家额外等待中", waited: 59 * time.Second, lowPriority: true, want: false},
+		{name: "低优先级玩家额外等待结束", waited: 60 * time.Second, lowPriority: true, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			player := &algorithm.Player{QueueTime: now.Add(-tt.waited), LowPriority: tt.lowPriority}
+			if got := Matchable(cfg, player, now); got != tt.want {
+				t.Errorf("Matchable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMutuallyAcceptable(t *testing.T) {
+	wide := SearchWindow{MMRDelta: 300, LevelDelta: 10, MaxPing: 120}
+	narrow := SearchWindow{MMRDelta: 50, LevelDelta: 5, MaxPing: 60}
+	tests := []struct {
+		name   string
+		p1, p2 *algorithm.Player
+		w1, w2 SearchWindow
+		want   bool
+	}{
+		{
+			name: "双方窗口内",
+			p1:   &algorithm.Player{MMR: 1500, Level: 10, Ping: 40},
+			p2:   &algorithm.Player{MMR: 1540, Level: 12, Ping: 40},
+			w1:   narrow, w2: narrow,
+			want: true,
+		},
+		{
+			name: "MMR差只在一方窗口内",
+			p1:   &algorithm.Player{MMR: 1500, Level: 10, Ping: 40},
+			p2:   &algorithm.Player{MMR: 1600, Level: 10, Ping: 40},
+			w1:   wide, w2: narrow,
+			want: false,
+		},
+		{
+			name: "等级差超出较小的窗口",
+			p1:   &algorithm.Player{MMR: 1500, Level: 10, Ping: 40},
+			p2:   &algorithm.Player{MMR: 1500, Level: 18, Ping: 40},
+			w1:   wide, w2: narrow,
+			want: false,
+		},
+		{
+			name: "等级差为0表示不限制",
+			p1:   &algorithm.Player{MMR: 1500, Level: 10, Ping: 40},
+			p2:   &algorithm.Player{MMR: 1500, Level: 50, Ping: 40},
+			w1:   SearchWindow{MMRDelta: 300}, w2: SearchWindow{MMRDelta: 300},
+			want: true,
+		},
+		{
+			name: "共同机房延迟超出上限",
+			p1:   &algorithm.Player{MMR: 1500, Level: 10, Pings: map[string]int{"sh": 20, "bj": 90}},
+			p2:   &algorithm.Player{MMR: 1500, Level: 10, Pings: map[string]int{"bj": 30}},
+			w1:   wide, w2: narrow,
+			want: false,
+		},
+		{
+			name: "不同优先级队列",
+			p1:   &algorithm.Player{MMR: 1500, Level: 10, LowPriority: true},
+			p2:   &algorithm.Player{MMR: 1500, Level: 10},
+			w1:   wide, w2: wide,
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MutuallyAcceptable(tt.p1, tt.w1, tt.p2, tt.w2); got != tt.want {
+				t.Errorf("MutuallyAcceptable() = %v, want %v", got, tt.want)
+			}
+			if got := MutuallyAcceptable(tt.p2, tt.w2, tt.p1, tt.w1); got != tt.want {
+				t.Errorf("MutuallyAcceptable() reversed = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
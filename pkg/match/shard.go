@@ -0,0 +1,325 @@
+package match
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mangooer/gamehub-arena/internal/cache"
+	"github.com/mangooer/gamehub-arena/internal/config"
+	"github.com/mangooer/gamehub-arena/internal/logger"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+var ErrLeaseLost = errors.New("bucket lease lost")
+
+// 获取或续约租约：租约空闲时递增隔离令牌并占有，已由本实例持有时只续期
+// KEYS: 租约, 令牌计数器
+// ARGV: 实例ID, 有效期（毫秒）
+// 返回持有的令牌，被其他实例持有时返回0
+const acquireLeaseScript = `
+local current = redis.call('GET', KEYS[1])
+if current then
+	local owner, token = string.match(current, '^(.*)|(%d+)$')
+	if owner == ARGV[1] then
+		redis.call('PEXPIRE', KEYS[1], ARGV[2])
+		return tonumber(token)
+	end
+	return 0
+end
+local token = redis.call('INCR', KEYS[2])
+redis.call('SET', KEYS[1], ARGV[1] .. '|' .. token, 'PX', ARGV[2])
+return token
+`
+
+// 续约租约，租约值（实例ID|令牌）不变时才续期，返回是否仍然持有
+// KEYS: 租约
+// ARGV: 租约值, 有效期（毫秒）
+const renewLeaseScript = `
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+	return 1
+end
+return 0
+`
+
+// 释放租约，只删除本实例持有的租约
+// KEYS: 租约
+// ARGV: 租约值
+const releaseLeaseScript = `
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`
+
+// 匹配桶：一个游戏模式在一个区域内的一个MMR段，是多实例间分配匹配工作的单位
+type MatchBucket struct {
+	GameMode string   `json:"game_mode"`
+	Region   string   `json:"region"`
+	Rank     MMRRange `json:"rank"`
+}
+
+func (b MatchBucket) ID() string {
+	return fmt.Sprintf("%s:%s:%s", b.GameMode, b.Region, b.Rank.Name)
+}
+
+// 匹配桶租约
+// Token 为隔离令牌，每次有实例重新获得租约时递增。认领对局时校验租约值，
+// 租约过期后被其他实例接管的旧持有者即使仍在运行，也无法再从该桶成局
+type BucketLease struct {
+	Bucket    MatchBucket `json:"bucket"`
+	Owner     string      `json:"owner"`
+	Token     int64       `json:"token"`
+	ExpiresAt time.Time   `json:"expires_at"` // 本地估计的过期时间，早于Redis中的实际过期时间
+}
+
+func (l *BucketLease) value() string {
+	return l.Owner + "|" + strconv.FormatInt(l.Token, 10)
+}
+
+type leaseContextKey struct{}
+
+// 在匹配上下文中携带桶租约，QueueManager.ClaimMatch 据此校验隔离令牌
+func withLease(ctx context.Context, lease *BucketLease) context.Context {
+	return context.WithValue(ctx, leaseContextKey{}, lease)
+}
+
+func leaseFrom(ctx context.Context) *BucketLease {
+	lease, _ := ctx.Value(leaseContextKey{}).(*BucketLease)
+	return lease
+}
+
+// ShardManager 维护本实例持有的匹配桶租约
+//
+// 各实例定期心跳登记到存活实例集合，按存活实例数平分所有桶：
+// 持有不足份额时获取空闲的桶，超出份额时释放多余的桶供新加入的实例获取。
+// 实例失联后其租约在 lease_ttl 内过期，其余实例的份额随之增加并自动接管。
+type ShardManager struct {
+	cache      cache.CacheService
+	config     *config.MatchConfig
+	logger     logger.Logger
+	instanceID string
+	buckets    []MatchBucket
+
+	leases map[string]*BucketLease
+	mu     sync.RWMutex
+}
+
+// NewShardManager 创建分片管理器，负责 buckets 中的所有桶
+func NewShardManager(cache cache.CacheService, config *config.MatchConfig, buckets []MatchBucket, logger logger.Logger) *ShardManager {
+	instanceID := config.Sharding.InstanceID
+	if instanceID == "" {
+		hostname, _ := os.Hostname()
+		instanceID = fmt.Sprintf("%s-%s", hostname, uuid.NewString()[:8])
+	}
+	return &ShardManager{
+		cache:      cache,
+		config:     config,
+		logger:     logger,
+		instanceID: instanceID,
+		buckets:    buckets,
+		leases:     make(map[string]*BucketLease),
+	}
+}
+
+func (s *ShardManager) InstanceID() string {
+	return s.instanceID
+}
+
+// RenewInterval 续约间隔，为有效期的三分之一
+func (s *ShardManager) RenewInterval() time.Duration {
+	return s.ttl() / 3
+}
+
+// Lease 返回本实例持有且未过期的桶租约
+func (s *ShardManager) Lease(bucket MatchBucket, now time.Time) (*BucketLease, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	lease, ok := s.leases[bucket.ID()]
+	if !ok || !now.Before(lease.ExpiresAt) {
+		return nil, false
+	}
+	return lease, true
+}
+
+// OwnedBuckets 本实例当前持有的桶数
+func (s *ShardManager) OwnedBuckets() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.leases)
+}
+
+// Rebalance 心跳、续约已持有的租约，并按存活实例数调整持有的桶
+// Redis 调用基于租约快照在锁外进行，完成后一次性发布新的租约集合，不阻塞各协程的 Lease；
+// 中途出错时同样发布已确定的结果，未续约的租约按本地过期时间失效
+func (s *ShardManager) Rebalance(ctx context.Context, now time.Time) error {
+	instances, err := s.heartbeat(ctx, now)
+	if err != nil {
+		return fmt.Errorf("failed to send heartbeat: %w", err)
+	}
+	share := (len(s.buckets) + instances - 1) / instances
+
+	s.mu.RLock()
+	leases := make(map[string]*BucketLease, len(s.leases))
+	for id, lease := range s.leases {
+		leases[id] = lease
+	}
+	s.mu.RUnlock()
+
+	err = s.rebalance(ctx, leases, share, now)
+	s.mu.Lock()
+	s.leases = leases
+	s.mu.Unlock()
+	return err
+}
+
+// 在租约快照上续约、释放和获取租约；已发布的租约不会被修改，续约后替换为新的租约
+func (s *ShardManager) rebalance(ctx context.Context, leases map[string]*BucketLease, share int, now time.Time) error {
+	for id, lease := range leases {
+		held, err := s.renew(ctx, lease)
+		if err != nil {
+			return fmt.Errorf("failed to renew lease %s: %w", id, err)
+		}
+		if !held {
+			delete(leases, id)
+			s.logger.GetLogger().Warn("Bucket lease lost",
+				zap.String("bucket", id),
+				zap.Int64("token", lease.Token),
+			)
+			continue
+		}
+		renewed := *lease
+		renewed.ExpiresAt = now.Add(s.ttl())
+		leases[id] = &renewed
+	}
+
+	// 超出份额时释放多余的桶
+	if len(leases) > share {
+		ids := make([]string, 0, len(leases))
+		for id := range leases {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids[share:] {
+			if err := s.release(ctx, leases[id]); err != nil {
+				return fmt.Errorf("failed to release lease %s: %w", id, err)
+			}
+			delete(leases, id)
+			s.logger.GetLogger().Info("Bucket lease released for rebalance", zap.String("bucket", id))
+		}
+	}
+
+	// 不足份额时获取空闲的桶，从按实例ID错开的位置开始以减少实例间的争抢
+	offset := int(instanceHash(s.instanceID) % uint32(max(1, len(s.buckets))))
+	for i := 0; i < len(s.buckets) && len(leases) < share; i++ {
+		bucket := s.buckets[(offset+i)%len(s.buckets)]
+		if _, ok := leases[bucket.ID()]; ok {
+			continue
+		}
+		lease, err := s.acquire(ctx, bucket, now)
+		if err != nil {
+			return fmt.Errorf("failed to acquire lease %s: %w", bucket.ID(), err)
+		}
+		if lease == nil {
+			continue
+		}
+		leases[bucket.ID()] = lease
+		s.logger.GetLogger().Info("Bucket lease acquired",
+			zap.String("bucket", bucket.ID()),
+			zap.Int64("token", lease.Token),
+		)
+	}
+	return nil
+}
+
+// ReleaseAll 释放所有租约，实例停止时调用以便其他实例立即接管
+func (s *ShardManager) ReleaseAll(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, lease := range s.leases {
+		if err := s.release(ctx, lease); err != nil {
+			return fmt.Errorf("failed to release lease %s: %w", id, err)
+		}
+		delete(s.leases, id)
+	}
+	if err := s.cache.ZRem(ctx, cache.MatchInstancesKey(), s.instanceID); err != nil {
+		return fmt.Errorf("failed to unregister instance: %w", err)
+	}
+	return nil
+}
+
+// 登记本实例并清理心跳过期的实例，返回存活实例数（至少为1）
+func (s *ShardManager) heartbeat(ctx context.Context, now time.Time) (int, error) {
+	key := cache.MatchInstancesKey()
+	if err := s.cache.ZAdd(ctx, key, redis.Z{
+		Score:  float64(now.Add(s.ttl()).UnixMilli()),
+		Member: s.instanceID,
+	}); err != nil {
+		return 0, err
+	}
+	if _, err := s.cache.ZRemRangeByScore(ctx, key, "-inf", strconv.FormatInt(now.UnixMilli(), 10)); err != nil {
+		return 0, err
+	}
+	count, err := s.cache.ZCard(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	return max(1, int(count)), nil
+}
+
+// 获取桶租约，被其他实例持有时返回 nil
+func (s *ShardManager) acquire(ctx context.Context, bucket MatchBucket, now time.Time) (*BucketLease, error) {
+	reply, err := s.cache.Eval(ctx, acquireLeaseScript,
+		[]string{cache.MatchLeaseKey(bucket.ID()), cache.MatchLeaseTokenKey(bucket.ID())},
+		s.instanceID, s.ttl().Milliseconds(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	token, _ := reply.(int64)
+	if token == 0 {
+		return nil, nil
+	}
+	return &BucketLease{
+		Bucket:    bucket,
+		Owner:     s.instanceID,
+		Token:     token,
+		ExpiresAt: now.Add(s.ttl()),
+	}, nil
+}
+
+func (s *ShardManager) renew(ctx context.Context, lease *BucketLease) (bool, error) {
+	reply, err := s.cache.Eval(ctx, renewLeaseScript,
+		[]string{cache.MatchLeaseKey(lease.Bucket.ID())},
+		lease.value(), s.ttl().Milliseconds(),
+	)
+	if err != nil {
+		return false, err
+	}
+	held, _ := reply.(int64)
+	return held == 1, nil
+}
+
+func (s *ShardManager) release(ctx context.Context, lease *BucketLease) error {
+	_, err := s.cache.Eval(ctx, releaseLeaseScript, []string{cache.MatchLeaseKey(lease.Bucket.ID())}, lease.value())
+	return err
+}
+
+func (s *ShardManager) ttl() time.Duration {
+	return time.Duration(s.config.Sharding.LeaseTTL) * time.Second
+}
+
+func instanceHash(instanceID string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(instanceID))
+	return h.Sum32()
+}
@@ -0,0 +1,172 @@
+package match
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mangooer/gamehub-arena/internal/cache"
+	"github.com/mangooer/gamehub-arena/internal/config"
+	"github.com/mangooer/gamehub-arena/pkg/algorithm"
+	"github.com/redis/go-redis/v9"
+)
+
+// 预计等待时间
+//
+// 每次成局时，按 (模式, 区域, MMR段) 记录每名玩家的实际等待时间，
+// 保存在 match:throughput:{mode}:{region}:{band}，成员为 "{等待秒数}:{match_id}:{user_id}"，
+// 分数为成局时间戳，只保留最近 window 秒。
+//
+// 对已等待 w 秒的玩家，预计剩余时间为近期等待时间超过 w 的样本的 E[W - w]；
+// 没有这样的样本时（玩家已比近期所有人等得更久），使用该分段的平均成局间隔。
+// 分段样本不足时逐步合并相邻分段，仍无样本时返回排队超时时间。
+type waitEstimator struct {
+	cache  cache.CacheService
+	config *config.MatchConfig
+
+	mu      sync.Mutex
+	samples map[string]*waitSamples
+}
+
+// 一个分段在时间窗口内的等待样本（秒）
+type waitSamples struct {
+	waits    []float64
+	loadedAt time.Time
+}
+
+func newWaitEstimator(cache cache.CacheService, config *config.MatchConfig) *waitEstimator {
+	return &waitEstimator{
+		cache:   cache,
+		config:  config,
+		samples: make(map[string]*waitSamples),
+	}
+}
+
+// 记录一场对局中每名玩家的等待时间
+func (w *waitEstimator) record(ctx context.Context, result *algorithm.MatchResult, matchedAt time.Time) error {
+	cutoff := strconv.FormatInt(matchedAt.Add(-w.window()).Unix(), 10)
+	trimmed := make(map[string]bool)
+	for _, p := range result.Players {
+		key := cache.MatchThroughputKey(p.GameMode, p.Region, w.band(p.EffectiveMMR()))
+		wait := math.Max(0, matchedAt.Sub(p.QueueTime).Seconds())
+		member := fmt.Sprintf("%d:%s:%d", int(wait), result.MatchID, p.ID)
+		if err := w.cache.ZAdd(ctx, key, redis.Z{Score: float64(matchedAt.Unix()), Member: member}); err != nil {
+			return fmt.Errorf("failed to record wait time: %w", err)
+		}
+		if !trimmed[key] {
+			trimmed[key] = true
+			if _, err := w.cache.ZRemRangeByScore(ctx, key, "-inf", "("+cutoff); err != nil {
+				return fmt.Errorf("failed to trim wait samples: %w", err)
+			}
+			if err := w.cache.Expire(ctx, key, 2*w.window()); err != nil {
+				return fmt.Errorf("failed to expire wait samples: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// 估计玩家的剩余等待时间
+func (w *waitEstimator) estimate(ctx context.Context, player *algorithm.Player) (time.Duration, error) {
+	waited := math.Max(0, time.Since(player.QueueTime).Seconds())
+	band := w.band(player.EffectiveMMR())
+
+	// 样本不足时向两侧合并分段，最多合并到 ±3 段
+	var waits []float64
+	for radius := 0; radius <= 3; radius++ {
+		bands := []int{band - radius, band + radius}
+		if radius == 0 {
+			bands = bands[:1]
+		}
+		for _, b := range bands {
+			if b < 0 {
+				continue
+			}
+			samples, err := w.load(ctx, player.GameMode, player.Region, b)
+			if err != nil {
+				return 0, err
+			}
+			waits = append(waits, samples...)
+		}
+		if len(waits) >= w.minSamples() {
+			break
+		}
+	}
+	if len(waits) == 0 {
+		return w.remaining(float64(w.config.Queue.Timeout), waited), nil
+	}
+
+	var residual float64
+	var longer int
+	for _, wait := range waits {
+		if wait > waited {
+			residual += wait - waited
+			longer++
+		}
+	}
+	if longer > 0 {
+		return time.Duration(residual / float64(longer) * float64(time.Second)), nil
+	}
+	// 已超过所有近期样本，按平均成局间隔估计
+	interval := w.window().Seconds() / float64(len(waits))
+	return w.remaining(math.Min(interval, float64(w.config.Queue.Timeout)), 0), nil
+}
+
+// 读取分段样本，按刷新间隔缓存在内存中
+func (w *waitEstimator) load(ctx context.Context, gameMode, region string, band int) ([]float64, error) {
+	key := cache.MatchThroughputKey(gameMode, region, band)
+	refresh := time.Duration(w.config.WaitEstimate.Refresh) * time.Second
+
+	w.mu.Lock()
+	cached, ok := w.samples[key]
+	w.mu.Unlock()
+	if ok && time.Since(cached.loadedAt) < refresh {
+		return cached.waits, nil
+	}
+
+	members, err := w.cache.ZRangeByScore(ctx, key, &redis.ZRangeBy{
+		Min: strconv.FormatInt(time.Now().Add(-w.window()).Unix(), 10),
+		Max: "+inf",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load wait samples: %w", err)
+	}
+	waits := make([]float64, 0, len(members))
+	for _, member := range members {
+		seconds, _, _ := strings.Cut(member, ":")
+		wait, err := strconv.ParseFloat(seconds, 64)
+		if err != nil {
+			continue
+		}
+		waits = append(waits, wait)
+	}
+
+	w.mu.Lock()
+	w.samples[key] = &waitSamples{waits: waits, loadedAt: time.Now()}
+	w.mu.Unlock()
+	return waits, nil
+}
+
+func (w *waitEstimator) remaining(total, waited float64) time.Duration {
+	return time.Duration(math.Max(0, total-waited) * float64(time.Second))
+}
+
+func (w *waitEstimator) band(mmr float64) int {
+	width := w.config.WaitEstimate.BandWidth
+	if width <= 0 {
+		width = 200
+	}
+	return int(math.Max(0, mmr) / width)
+}
+
+func (w *waitEstimator) window() time.Duration {
+	return time.Duration(w.config.WaitEstimate.Window) * time.Second
+}
+
+func (w *waitEstimator) minSamples() int {
+	return max(1, w.config.WaitEstimate.MinSamples)
+}
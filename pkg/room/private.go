@@ -0,0 +1,177 @@
+package room
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/mangooer/gamehub-arena/internal/cache"
+	"github.com/mangooer/gamehub-arena/internal/models"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrInvalidPassword     = errors.New("invalid room password")
+	ErrPasswordRequired    = errors.New("password or invite required")
+	ErrWrongPassword       = errors.New("wrong room password")
+	ErrInvalidInvite       = errors.New("invalid or expired invite")
+	ErrTooManyAttempts     = errors.New("too many failed password attempts")
+	ErrInviteNotAllowed    = errors.New("only room players can invite")
+	errInviteTokenNotFound = errors.New("invite token not found")
+)
+
+// 邀请令牌的随机字节数
+const inviteTokenBytes = 16
+
+// 占用一次密码尝试，窗口内首次尝试时设置过期时间，返回窗口内的尝试次数
+// 在校验密码前计数，并发请求无法绕过次数上限
+// KEYS: 尝试次数
+// ARGV: 窗口（毫秒）
+const reservePasswordAttemptScript = `
+local count = redis.call('INCR', KEYS[1])
+if count == 1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return count
+`
+
+// 房间邀请，有效期内凭令牌加入私人房间无需密码
+type RoomInvite struct {
+	RoomID    uint64    `json:"room_id"`
+	RoomCode  string    `json:"room_code"`
+	Token     string    `json:"token"`
+	Link      string    `json:"link"` // 加入链接
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// CreateInvite 房间内的玩家为等待中的房间生成邀请
+func (s *RoomService) CreateInvite(ctx context.Context, roomID, userID uint64) (*RoomInvite, error) {
+	room, err := s.GetRoom(ctx, roomID)
+	if err != nil {
+		return nil, err
+	}
+	if findPlayer(room, userID) == nil {
+		return nil, ErrInviteNotAllowed
+	}
+	if room.Status != models.RoomStatusWaiting {
+		return nil, fmt.Errorf("%w: room is %s", ErrRoomClosed, room.Status)
+	}
+
+	raw := make([]byte, inviteTokenBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("failed to generate invite token: %w", err)
+	}
+	token := hex.EncodeToString(raw)
+	ttl := time.Duration(s.config.Room.InviteTTL) * time.Second
+	if err := s.cache.Set(ctx, cache.RoomInviteKey(token), room.ID, ttl); err != nil {
+		return nil, fmt.Errorf("failed to save invite: %w", err)
+	}
+
+	invite := &RoomInvite{
+		RoomID:    room.ID,
+		RoomCode:  room.RoomCode,
+		Token:     token,
+		Link:      fmt.Sprintf("%s/%s?invite=%s", s.config.Room.InviteBaseURL, url.PathEscape(room.RoomCode), token),
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	s.logger.GetLogger().Info("Room invite created",
+		zap.Uint64("room_id", room.ID),
+		zap.Uint64("user_id", userID),
+		zap.Time("expires_at", invite.ExpiresAt),
+	)
+	return invite, nil
+}
+
+// 校验加入私人房间的权限：已在房间中的玩家直接通过，其次校验邀请，最后校验密码
+// 只有等待中的房间可以加入，其他状态在校验邀请和密码前拒绝
+func (s *RoomService) authorize(ctx context.Context, room *models.GameRoom, userID uint64, req *JoinRoomRequest) error {
+	if room.Status != models.RoomStatusWaiting {
+		return fmt.Errorf("%w: room is %s", ErrRoomClosed, room.Status)
+	}
+	if !room.IsPrivate || findPlayer(room, userID) != nil {
+		return nil
+	}
+
+	if req.InviteToken != "" {
+		err := s.checkInvite(ctx, room, req.InviteToken)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, errInviteTokenNotFound) {
+			return err
+		}
+		if req.Password == "" {
+			return ErrInvalidInvite
+		}
+	}
+	if req.Password == "" || room.PasswordHash == nil {
+		return ErrPasswordRequired
+	}
+	return s.checkPassword(ctx, room, userID, req.Password)
+}
+
+// 邀请令牌有效且属于该房间
+func (s *RoomService) checkInvite(ctx context.Context, room *models.GameRoom, token string) error {
+	value, err := s.cache.Get(ctx, cache.RoomInviteKey(token))
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return errInviteTokenNotFound
+		}
+		return fmt.Errorf("failed to get invite: %w", err)
+	}
+	if value != strconv.FormatUint(room.ID, 10) {
+		return errInviteTokenNotFound
+	}
+	return nil
+}
+
+// 校验房间密码，先占用一次尝试再校验，用户对该房间在时间窗口内的尝试次数超过上限后直接拒绝
+// 计数按用户和房间区分，密码正确只清除该房间的计数
+func (s *RoomService) checkPassword(ctx context.Context, room *models.GameRoom, userID uint64, password string) error {
+	key := cache.RoomPasswordAttemptsKey(room.ID, userID)
+	window := time.Duration(s.config.Room.PasswordAttemptWindow) * time.Second
+	reply, err := s.cache.Eval(ctx, reservePasswordAttemptScript, []string{key}, window.Milliseconds())
+	if err != nil {
+		return fmt.Errorf("failed to reserve password attempt: %w", err)
+	}
+	attempts, _ := reply.(int64)
+	if limit := s.config.Room.PasswordMaxAttempts; limit > 0 && attempts > int64(limit) {
+		return ErrTooManyAttempts
+	}
+
+	if s.passwords.VerifyPassword(*room.PasswordHash, password) {
+		if err := s.cache.Del(ctx, key); err != nil {
+			s.logger.GetLogger().Warn("failed to reset password attempts",
+				zap.Uint64("room_id", room.ID),
+				zap.Uint64("user_id", userID),
+				zap.Error(err),
+			)
+		}
+		return nil
+	}
+
+	s.logger.GetLogger().Warn("Wrong room password",
+		zap.Uint64("room_id", room.ID),
+		zap.Uint64("user_id", userID),
+		zap.Int64("attempts", attempts),
+	)
+	return ErrWrongPassword
+}
+
+// 校验房间密码长度后以 bcrypt 加密
+func (s *RoomService) hashPassword(password string) (string, error) {
+	if len(password) < s.config.Room.PasswordMinLength || len(password) > s.config.Room.PasswordMaxLength {
+		return "", fmt.Errorf("%w: length must be between %d and %d", ErrInvalidPassword, s.config.Room.PasswordMinLength, s.config.Room.PasswordMaxLength)
+	}
+	hash, err := s.passwords.HashSecret(password)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash room password: %w", err)
+	}
+	return hash, nil
+}
@@ -0,0 +1,573 @@
+package room
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"time"
+
+	"github.com/mangooer/gamehub-arena/internal/auth"
+	"github.com/mangooer/gamehub-arena/internal/cache"
+	"github.com/mangooer/gamehub-arena/internal/config"
+	"github.com/mangooer/gamehub-arena/internal/logger"
+	"github.com/mangooer/gamehub-arena/internal/models"
+	"github.com/mangooer/gamehub-arena/internal/repository"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrRoomNotFound      = errors.New("room not found")
+	ErrInvalidRoom       = errors.New("invalid room")
+	ErrRoomClosed        = errors.New("room is not accepting players")
+	ErrRoomFull          = errors.New("room is full")
+	ErrTeamFull          = errors.New("team is full")
+	ErrInvalidTeam       = errors.New("invalid team")
+	ErrAlreadyInRoom     = errors.New("player already in room")
+	ErrNotInRoom         = errors.New("player not in room")
+	ErrNotCaptain        = errors.New("only room captain can do this")
+	ErrKickSelf          = errors.New("captain cannot kick themselves")
+	ErrPlayersNotReady   = errors.New("not all players are ready")
+	ErrInvalidTransition = errors.New("invalid room status transition")
+)
+
+// 房间人数范围，与 game_rooms.max_players 的约束一致
+const (
+	minRoomPlayers = 2
+	maxRoomPlayers = 10
+)
+
+// 每队最多的位置数，与 room_players.position 的约束一致
+const maxTeamPositions = 5
+
+// 房间码字母表，去掉了易混淆的 0/O/1/I
+const roomCodeAlphabet = "23456789ABCDEFGHJKLMNPQRSTUVWXYZ"
+
+// 房间码长度，10位约50比特随机数，无法通过枚举找到私人房间
+const roomCodeLength = 10
+
+// 房间码冲突时重新生成的次数
+const roomCodeAttempts = 5
+
+// 房间状态的合法转换，未结束的房间都可以取消
+var roomTransitions = map[string][]string{
+	models.RoomStatusWaiting:    {models.RoomStatusStarting, models.RoomStatusCancelled},
+	models.RoomStatusStarting:   {models.RoomStatusInProgress, models.RoomStatusCancelled},
+	models.RoomStatusInProgress: {models.RoomStatusFinished, models.RoomStatusCancelled},
+}
+
+// 创建房间参数
+type CreateRoomRequest struct {
+	Name       string `json:"name"`
+	GameMode   string `json:"game_mode"`
+	MaxPlayers int    `json:"max_players"`
+	MapName    string `json:"map_name,omitempty"` // 为空时使用默认地图
+	Private    bool   `json:"private,omitempty"`  // 私人房间不出现在房间列表中，只能凭密码或邀请加入
+	Password   string `json:"password,omitempty"` // 设置密码时房间为私人房间
+}
+
+// 加入房间参数，公开房间不需要密码和邀请
+type JoinRoomRequest struct {
+	Team        string `json:"team,omitempty"`         // 为空时加入人数较少的队伍
+	Password    string `json:"password,omitempty"`     // 私人房间密码
+	InviteToken string `json:"invite_token,omitempty"` // 持有有效邀请时无需密码
+}
+
+// RoomService 自定义房间的生命周期
+//
+// 房间状态按 waiting -> starting -> in_progress -> finished 推进，未结束的房间可以取消。
+// 创建者作为队长加入 team_a；其他玩家只能在 waiting 状态加入，占用所选队伍中最小的空闲位置。
+// 队长离开或被踢出后，队长转交给最早加入的玩家；最后一名玩家离开时房间取消。
+// 所有修改都在房间行锁内进行，current_players 总是按房间内玩家重新计算，
+// 因此并发加入不会超过 max_players，也不会占用同一位置。
+//
+// 私人房间不出现在房间列表中，加入时需要密码或房间内玩家发出的邀请，
+// 密码以 bcrypt 保存，每个用户在时间窗口内输错密码的次数受限。
+type RoomService struct {
+	repo      *repository.RoomRepository
+	cache     cache.CacheService
+	passwords *auth.PasswordService
+	config    *config.Config
+	logger    logger.Logger
+}
+
+func NewRoomService(repo *repository.RoomRepository, cache cache.CacheService, passwords *auth.PasswordService, config *config.Config, logger logger.Logger) *RoomService {
+	return &RoomService{
+		repo:      repo,
+		cache:     cache,
+		passwords: passwords,
+		config:    config,
+		logger:    logger,
+	}
+}
+
+// CreateRoom 创建房间，创建者成为队长
+func (s *RoomService) CreateRoom(ctx context.Context, creatorID uint64, req *CreateRoomRequest) (*models.GameRoom, error) {
+	if req == nil || req.Name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidRoom)
+	}
+	if !slices.Contains(s.config.Match.Queue.GameModes, req.GameMode) {
+		return nil, fmt.Errorf("%w: unknown game mode %s", ErrInvalidRoom, req.GameMode)
+	}
+	if req.MaxPlayers < minRoomPlayers || req.MaxPlayers > maxRoomPlayers {
+		return nil, fmt.Errorf("%w: max players must be between %d and %d", ErrInvalidRoom, minRoomPlayers, maxRoomPlayers)
+	}
+
+	room := &models.GameRoom{
+		Name:           req.Name,
+		MaxPlayers:     req.MaxPlayers,
+		CurrentPlayers: 1,
+		Status:         models.RoomStatusWaiting,
+		GameMode:       req.GameMode,
+		MapName:        req.MapName,
+		IsPrivate:      req.Private || req.Password != "",
+		CreatedBy:      creatorID,
+	}
+	if room.MapName == "" {
+		room.MapName = "default_map"
+	}
+	if req.Password != "" {
+		hash, err := s.hashPassword(req.Password)
+		if err != nil {
+			return nil, err
+		}
+		room.PasswordHash = &hash
+	}
+	if err := s.createWithUniqueCode(room, creatorID); err != nil {
+		return nil, err
+	}
+
+	s.logger.GetLogger().Info("Room created",
+		zap.Uint64("room_id", room.ID),
+		zap.String("room_code", room.RoomCode),
+		zap.Uint64("creator_id", creatorID),
+		zap.String("game_mode", room.GameMode),
+		zap.Int("max_players", room.MaxPlayers),
+		zap.Bool("private", room.IsPrivate),
+	)
+	return room, nil
+}
+
+// 以随机房间码创建房间，写入因房间码已存在而失败时换一个房间码重试
+func (s *RoomService) createWithUniqueCode(room *models.GameRoom, creatorID uint64) error {
+	for attempt := 0; attempt < roomCodeAttempts; attempt++ {
+		code, err := newRoomCode()
+		if err != nil {
+			return fmt.Errorf("failed to generate room code: %w", err)
+		}
+		room.ID, room.RoomCode = 0, code
+		position := 1
+		creator := &models.RoomPlayer{
+			UserID:    creatorID,
+			Team:      models.RoomTeamA,
+			Position:  &position,
+			IsCaptain: true,
+			JoinedAt:  time.Now(),
+		}
+		err = s.repo.CreateRoom(room, creator)
+		if err == nil {
+			return nil
+		}
+		if exists, checkErr := s.repo.CodeExists(code); checkErr != nil || !exists {
+			return fmt.Errorf("failed to create room: %w", err)
+		}
+		s.logger.GetLogger().Warn("Room code collision, retrying", zap.String("room_code", code))
+	}
+	return fmt.Errorf("failed to create room: no unique room code after %d attempts", roomCodeAttempts)
+}
+
+// ListPublicRooms 获取等待中的公开房间，gameMode 为空时不限模式
+func (s *RoomService) ListPublicRooms(ctx context.Context, gameMode string, limit, offset int) ([]models.GameRoom, error) {
+	return s.repo.ListWaiting(gameMode, limit, offset)
+}
+
+// GetRoom 获取房间及房间内的玩家
+func (s *RoomService) GetRoom(ctx context.Context, roomID uint64) (*models.GameRoom, error) {
+	room, err := s.repo.GetByID(roomID)
+	if err != nil {
+		return nil, s.roomError(err)
+	}
+	return room, nil
+}
+
+// GetRoomByCode 根据房间码获取房间
+func (s *RoomService) GetRoomByCode(ctx context.Context, roomCode string) (*models.GameRoom, error) {
+	room, err := s.repo.GetByCode(roomCode)
+	if err != nil {
+		return nil, s.roomError(err)
+	}
+	return room, nil
+}
+
+// JoinRoom 加入等待中的房间，私人房间需要密码或有效邀请
+// 离开过房间的玩家重新加入时复用原记录
+func (s *RoomService) JoinRoom(ctx context.Context, roomID, userID uint64, req *JoinRoomRequest) (*models.RoomPlayer, error) {
+	room, err := s.GetRoom(ctx, roomID)
+	if err != nil {
+		return nil, err
+	}
+	return s.join(ctx, room, userID, req)
+}
+
+// JoinRoomByCode 通过房间码（如邀请链接）加入房间
+func (s *RoomService) JoinRoomByCode(ctx context.Context, roomCode string, userID uint64, req *JoinRoomRequest) (*models.RoomPlayer, error) {
+	room, err := s.GetRoomByCode(ctx, roomCode)
+	if err != nil {
+		return nil, err
+	}
+	return s.join(ctx, room, userID, req)
+}
+
+// 校验私人房间的访问权限后在房间行锁内加入，密码校验较慢，不在锁内进行
+func (s *RoomService) join(ctx context.Context, room *models.GameRoom, userID uint64, req *JoinRoomRequest) (*models.RoomPlayer, error) {
+	if req == nil {
+		req = &JoinRoomRequest{}
+	}
+	team := req.Team
+	if team != "" && team != models.RoomTeamA && team != models.RoomTeamB {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidTeam, team)
+	}
+	if err := s.authorize(ctx, room, userID, req); err != nil {
+		return nil, err
+	}
+
+	roomID := room.ID
+	var joined *models.RoomPlayer
+	err := s.update(roomID, func(tx *repository.RoomTx, room *models.GameRoom) error {
+		if room.Status != models.RoomStatusWaiting {
+			return fmt.Errorf("%w: room is %s", ErrRoomClosed, room.Status)
+		}
+		if findPlayer(room, userID) != nil {
+			return ErrAlreadyInRoom
+		}
+		if len(room.Players) >= room.MaxPlayers {
+			return ErrRoomFull
+		}
+
+		team, position, err := freeSlot(room, team)
+		if err != nil {
+			return err
+		}
+		player, err := tx.FindPlayer(room.ID, userID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			player = &models.RoomPlayer{RoomID: room.ID, UserID: userID}
+		} else if err != nil {
+			return err
+		}
+		player.Team = team
+		player.Position = &position
+		player.IsReady = false
+		player.IsCaptain = len(room.Players) == 0
+		player.JoinedAt = time.Now()
+		player.LeftAt = nil
+		if err := tx.SavePlayer(player); err != nil {
+			return err
+		}
+
+		room.CurrentPlayers = len(room.Players) + 1
+		joined = player
+		return tx.UpdateRoom(room)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.GetLogger().Info("Player joined room",
+		zap.Uint64("room_id", roomID),
+		zap.Uint64("user_id", userID),
+		zap.String("team", joined.Team),
+		zap.Int("position", *joined.Position),
+	)
+	return joined, nil
+}
+
+// LeaveRoom 玩家离开房间
+func (s *RoomService) LeaveRoom(ctx context.Context, roomID, userID uint64) error {
+	err := s.update(roomID, func(tx *repository.RoomTx, room *models.GameRoom) error {
+		return s.removePlayer(tx, room, userID)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.logger.GetLogger().Info("Player left room",
+		zap.Uint64("room_id", roomID),
+		zap.Uint64("user_id", userID),
+	)
+	return nil
+}
+
+// KickPlayer 队长在房间等待时踢出玩家
+func (s *RoomService) KickPlayer(ctx context.Context, roomID, captainID, targetID uint64) error {
+	if captainID == targetID {
+		return ErrKickSelf
+	}
+	err := s.update(roomID, func(tx *repository.RoomTx, room *models.GameRoom) error {
+		if err := requireCaptain(room, captainID); err != nil {
+			return err
+		}
+		if room.Status != models.RoomStatusWaiting {
+			return fmt.Errorf("%w: room is %s", ErrRoomClosed, room.Status)
+		}
+		return s.removePlayer(tx, room, targetID)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.logger.GetLogger().Info("Player kicked from room",
+		zap.Uint64("room_id", roomID),
+		zap.Uint64("captain_id", captainID),
+		zap.Uint64("user_id", targetID),
+	)
+	return nil
+}
+
+// SetReady 玩家在房间等待时设置准备状态
+func (s *RoomService) SetReady(ctx context.Context, roomID, userID uint64, ready bool) error {
+	return s.update(roomID, func(tx *repository.RoomTx, room *models.GameRoom) error {
+		if room.Status != models.RoomStatusWaiting {
+			return fmt.Errorf("%w: room is %s", ErrRoomClosed, room.Status)
+		}
+		player := findPlayer(room, userID)
+		if player == nil {
+			return ErrNotInRoom
+		}
+		if player.IsReady == ready {
+			return nil
+		}
+		player.IsReady = ready
+		return tx.SavePlayer(player)
+	})
+}
+
+// StartGame 队长开始游戏，房间进入 starting，等待游戏服务器就绪
+// 房间至少两名玩家，且除队长外的玩家都已准备
+func (s *RoomService) StartGame(ctx context.Context, roomID, captainID uint64) error {
+	err := s.update(roomID, func(tx *repository.RoomTx, room *models.GameRoom) error {
+		if err := requireCaptain(room, captainID); err != nil {
+			return err
+		}
+		if len(room.Players) < minRoomPlayers {
+			return fmt.Errorf("%w: need at least %d players", ErrPlayersNotReady, minRoomPlayers)
+		}
+		for _, p := range room.Players {
+			if !p.IsCaptain && !p.IsReady {
+				return fmt.Errorf("%w: player %d", ErrPlayersNotReady, p.UserID)
+			}
+		}
+		if err := transition(room, models.RoomStatusStarting); err != nil {
+			return err
+		}
+		return tx.UpdateRoom(room)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.logger.GetLogger().Info("Room starting",
+		zap.Uint64("room_id", roomID),
+		zap.Uint64("captain_id", captainID),
+	)
+	return nil
+}
+
+// BeginGame 游戏服务器就绪后开始对局，记录开始时间
+func (s *RoomService) BeginGame(ctx context.Context, roomID uint64) error {
+	return s.changeStatus(roomID, models.RoomStatusInProgress)
+}
+
+// FinishGame 对局结束，记录结束时间
+func (s *RoomService) FinishGame(ctx context.Context, roomID uint64) error {
+	return s.changeStatus(roomID, models.RoomStatusFinished)
+}
+
+// CancelRoom 取消未结束的房间，userID 非0时只有队长可以取消
+func (s *RoomService) CancelRoom(ctx context.Context, roomID, userID uint64) error {
+	err := s.update(roomID, func(tx *repository.RoomTx, room *models.GameRoom) error {
+		if userID != 0 {
+			if err := requireCaptain(room, userID); err != nil {
+				return err
+			}
+		}
+		if err := transition(room, models.RoomStatusCancelled); err != nil {
+			return err
+		}
+		return tx.UpdateRoom(room)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.logger.GetLogger().Info("Room cancelled",
+		zap.Uint64("room_id", roomID),
+		zap.Uint64("user_id", userID),
+	)
+	return nil
+}
+
+func (s *RoomService) changeStatus(roomID uint64, status string) error {
+	var from string
+	err := s.update(roomID, func(tx *repository.RoomTx, room *models.GameRoom) error {
+		from = room.Status
+		if err := transition(room, status); err != nil {
+			return err
+		}
+		return tx.UpdateRoom(room)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.logger.GetLogger().Info("Room status changed",
+		zap.Uint64("room_id", roomID),
+		zap.String("from", from),
+		zap.String("to", status),
+	)
+	return nil
+}
+
+// 将玩家移出房间：释放位置，必要时转交队长，最后一名玩家离开时取消房间
+func (s *RoomService) removePlayer(tx *repository.RoomTx, room *models.GameRoom, userID uint64) error {
+	player := findPlayer(room, userID)
+	if player == nil {
+		return ErrNotInRoom
+	}
+	switch room.Status {
+	case models.RoomStatusFinished, models.RoomStatusCancelled:
+		return fmt.Errorf("%w: room is %s", ErrRoomClosed, room.Status)
+	}
+
+	now := time.Now()
+	wasCaptain := player.IsCaptain
+	player.Position = nil
+	player.IsReady = false
+	player.IsCaptain = false
+	player.LeftAt = &now
+	if err := tx.SavePlayer(player); err != nil {
+		return err
+	}
+
+	var remaining []*models.RoomPlayer
+	for i := range room.Players {
+		if room.Players[i].UserID != userID {
+			remaining = append(remaining, &room.Players[i])
+		}
+	}
+	if wasCaptain && len(remaining) > 0 {
+		// 队长转交给最早加入的玩家
+		captain := remaining[0]
+		captain.IsCaptain = true
+		if err := tx.SavePlayer(captain); err != nil {
+			return err
+		}
+		s.logger.GetLogger().Info("Room captain transferred",
+			zap.Uint64("room_id", room.ID),
+			zap.Uint64("from", userID),
+			zap.Uint64("to", captain.UserID),
+		)
+	}
+
+	room.CurrentPlayers = len(remaining)
+	if len(remaining) == 0 {
+		if err := transition(room, models.RoomStatusCancelled); err != nil {
+			return err
+		}
+	}
+	return tx.UpdateRoom(room)
+}
+
+// 锁定房间后执行修改，房间不存在时返回 ErrRoomNotFound
+func (s *RoomService) update(roomID uint64, fn func(tx *repository.RoomTx, room *models.GameRoom) error) error {
+	return s.roomError(s.repo.WithRoomLock(roomID, fn))
+}
+
+func (s *RoomService) roomError(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrRoomNotFound
+	}
+	return err
+}
+
+// 按状态转换表修改房间状态，进入 in_progress 时记录开始时间，结束或取消时记录结束时间
+func transition(room *models.GameRoom, to string) error {
+	if !slices.Contains(roomTransitions[room.Status], to) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, room.Status, to)
+	}
+	now := time.Now()
+	switch to {
+	case models.RoomStatusInProgress:
+		room.StartedAt = &now
+	case models.RoomStatusFinished, models.RoomStatusCancelled:
+		room.EndedAt = &now
+	}
+	room.Status = to
+	return nil
+}
+
+func requireCaptain(room *models.GameRoom, userID uint64) error {
+	player := findPlayer(room, userID)
+	if player == nil {
+		return ErrNotInRoom
+	}
+	if !player.IsCaptain {
+		return ErrNotCaptain
+	}
+	return nil
+}
+
+func findPlayer(room *models.GameRoom, userID uint64) *models.RoomPlayer {
+	for i := range room.Players {
+		if room.Players[i].UserID == userID {
+			return &room.Players[i]
+		}
+	}
+	return nil
+}
+
+// 选择队伍中最小的空闲位置，team 为空时选择人数较少的队伍（相同时为 team_a）
+func freeSlot(room *models.GameRoom, team string) (string, int, error) {
+	capacity := min(maxTeamPositions, (room.MaxPlayers+1)/2)
+	taken := map[string]map[int]bool{
+		models.RoomTeamA: {},
+		models.RoomTeamB: {},
+	}
+	for _, p := range room.Players {
+		if p.Position != nil && taken[p.Team] != nil {
+			taken[p.Team][*p.Position] = true
+		}
+	}
+
+	teams := []string{team}
+	if team == "" {
+		teams = []string{models.RoomTeamA, models.RoomTeamB}
+		if len(taken[models.RoomTeamB]) < len(taken[models.RoomTeamA]) {
+			teams = []string{models.RoomTeamB, models.RoomTeamA}
+		}
+	}
+	for _, t := range teams {
+		for position := 1; position <= capacity; position++ {
+			if !taken[t][position] {
+				return t, position, nil
+			}
+		}
+	}
+	return "", 0, fmt.Errorf("%w: %v", ErrTeamFull, teams)
+}
+
+// 生成随机房间码
+func newRoomCode() (string, error) {
+	code := make([]byte, roomCodeLength)
+	limit := big.NewInt(int64(len(roomCodeAlphabet)))
+	for i := range code {
+		n, err := rand.Int(rand.Reader, limit)
+		if err != nil {
+			return "", err
+		}
+		code[i] = roomCodeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}